@@ -0,0 +1,118 @@
+package main
+
+import (
+	"expvar"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	// Plugins compiled in, enabled per workspace by config
+	_ "github.com/dysfn/wasb/plugins/echo"
+	_ "github.com/dysfn/wasb/plugins/tldr"
+	"github.com/dysfn/wasb/wasb"
+)
+
+const defaultConfigFile = "config.json"
+
+var configFile string
+
+func main() {
+	flags := flag.NewFlagSet("wasb", flag.ExitOnError)
+	flags.StringVar(&configFile, "config", defaultConfigFile, "")
+	err := flags.Parse(os.Args[1:])
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("Loading config (filename: %s)...", configFile)
+	cfg, err := wasb.GetCfg(configFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	log.Printf("Config loaded")
+
+	// A config without workspaces describes a single one
+	workspaces := cfg.Workspaces
+	if len(workspaces) == 0 {
+		workspaces = []*wasb.Cfg{cfg}
+	}
+
+	// Channel for receiving OS error signals
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+
+	// Channel for broadcasting "done" signals to all workspaces
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	mux := http.NewServeMux()
+	for i, wsCfg := range workspaces {
+		if wsCfg.Name == "" {
+			wsCfg.Name = fmt.Sprintf("workspace%d", i+1)
+		}
+		// Workspaces without workers of their own get the top-level ones,
+		// and at least one
+		if wsCfg.Workers <= 0 {
+			wsCfg.Workers = cfg.Workers
+		}
+		if wsCfg.Workers <= 0 {
+			wsCfg.Workers = 1
+		}
+		if wsCfg.Events && cfg.ListenAddr == "" {
+			log.Fatalf("Workspace %s receives events over HTTP but listenaddr is not set", wsCfg.Name)
+		}
+
+		ws, err := wasb.Connect(wsCfg)
+		if err != nil {
+			log.Fatalf("Error connecting workspace %s: %s", wsCfg.Name, err)
+		}
+
+		err = ws.EnablePlugins()
+		if err != nil {
+			log.Fatalf("Error enabling plugins in workspace %s: %s", ws.Name, err)
+		}
+		err = ws.Start()
+		if err != nil {
+			log.Fatalf("Error starting workspace %s: %s", ws.Name, err)
+		}
+
+		prefix := "/slack/" + ws.Name
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, ws.Handler()))
+
+		log.Printf("Launching the bot (workspace: %s, workers: %d)...", ws.Name, wsCfg.Workers)
+		wg.Add(1)
+		go func(ws *wasb.Workspace, workers int) {
+			defer wg.Done()
+			err := wasb.Run(ws, workers, done)
+			if err != nil {
+				log.Printf("Error tearing down workspace %s: %s", ws.Name, err)
+			}
+		}(ws, wsCfg.Workers)
+	}
+
+	if cfg.ListenAddr != "" {
+		log.Printf("Serving events, slash commands and interactivity (addr: %s)...", cfg.ListenAddr)
+		go func() {
+			log.Fatalln(http.ListenAndServe(cfg.ListenAddr, mux))
+		}()
+	}
+
+	if cfg.DebugAddr != "" {
+		debug := http.NewServeMux()
+		debug.Handle("/debug/vars", expvar.Handler())
+		log.Printf("Serving metrics (addr: %s)...", cfg.DebugAddr)
+		go func() {
+			log.Fatalln(http.ListenAndServe(cfg.DebugAddr, debug))
+		}()
+	}
+
+	// Receive OS error signal, then stop all workspaces
+	<-sigs
+	close(done)
+	wg.Wait()
+}
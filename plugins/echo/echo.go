@@ -0,0 +1,44 @@
+package echo
+
+import (
+	"encoding/json"
+
+	"github.com/dysfn/wasb/wasb"
+)
+
+func init() {
+	wasb.RegisterPlugin("echo", func() wasb.Plugin { return &Echo{} })
+}
+
+// Echo makes the bot repeat every message it sees that no other handler
+// takes. It takes no config.
+type Echo struct {
+	wasb.BasePlugin
+	replier *wasb.Replier
+}
+
+func (bot *Echo) Name() string {
+	return "echo"
+}
+
+func (bot *Echo) Init(cfg json.RawMessage, svc *wasb.Services) error {
+	bot.replier = svc.Replier
+	return nil
+}
+
+func (bot *Echo) Register(r *wasb.Router) {
+	r.HandleMessage(bot.isEchoable, bot.echo,
+		wasb.Named("echo"),
+		wasb.Priority(-1),
+		wasb.Usage("<anything>"),
+		wasb.Description("Repeat what you say"))
+}
+
+func (bot *Echo) isEchoable(m *wasb.Msg) bool {
+	return m.Type == "message" && m.Text != ""
+}
+
+func (bot *Echo) echo(m *wasb.Msg) error {
+	err := bot.replier.ReplyText(m, m.Text)
+	return err
+}
@@ -0,0 +1,212 @@
+// Package article downloads web pages and extracts their readable
+// content: title, byline, publishing date and the text of the article
+// without navigation, ads and scripts.
+package article
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxSize      = 2 << 20
+	DefaultTimeout      = 20 * time.Second
+	DefaultMaxRedirects = 5
+
+	userAgent = "Mozilla/5.0 (compatible; wasb-tldr/1.0)"
+)
+
+var (
+	ErrTooLarge          = errors.New("article: page too large")
+	ErrNotHTML           = errors.New("article: not an HTML page")
+	ErrNoContent         = errors.New("article: no readable content")
+	ErrTooManyRedirects  = errors.New("article: too many redirects")
+	ErrUnsupportedScheme = errors.New("article: only http and https URLs are supported")
+	ErrForbiddenAddress  = errors.New("article: pages on private networks are not fetched")
+)
+
+// StatusError is returned for pages that could not be fetched.
+type StatusError struct {
+	URL  string
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("article: fetching %s: %d %s", e.URL, e.Code, http.StatusText(e.Code))
+}
+
+type Article struct {
+	// URL the article was fetched from, after redirects
+	URL string
+
+	Title     string
+	Byline    string
+	SiteName  string
+	Excerpt   string
+	Published time.Time
+
+	// Text of the article, one paragraph each
+	Paragraphs []string
+}
+
+// Text returns the article's paragraphs separated by blank lines.
+func (a *Article) Text() string {
+	return strings.Join(a.Paragraphs, "\n\n")
+}
+
+// Length returns the number of characters of text in the article.
+func (a *Article) Length() int {
+	n := 0
+	for _, p := range a.Paragraphs {
+		n += len([]rune(p))
+	}
+	return n
+}
+
+// Parse extracts the article from an HTML page. contentType, e.g. from
+// the response's Content-Type header, is used to decode the page and may
+// be empty.
+func Parse(r io.Reader, contentType string) (*Article, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := parseHTML(decode(body, contentType))
+	meta := metadata(doc)
+	a := &Article{
+		SiteName:  first(meta, "og:site_name", "application-name"),
+		Excerpt:   first(meta, "og:description", "description", "twitter:description", "dc.description"),
+		Byline:    byline(doc, meta),
+		Published: published(doc, meta),
+	}
+	a.Title = title(doc, meta, a.SiteName)
+
+	a.Paragraphs = content(doc)
+	if len(a.Paragraphs) == 0 {
+		return nil, ErrNoContent
+	}
+	return a, nil
+}
+
+// Fetcher downloads articles within size, time and redirect limits, from
+// public addresses only.
+type Fetcher struct {
+	MaxSize      int64
+	MaxRedirects int
+	client       *http.Client
+
+	// Lets tests fetch from local servers
+	allowPrivate bool
+}
+
+// NewFetcher returns a fetcher giving up on a page after timeout, or
+// DefaultTimeout if zero.
+func NewFetcher(timeout time.Duration) *Fetcher {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	f := &Fetcher{
+		MaxSize:      DefaultMaxSize,
+		MaxRedirects: DefaultMaxRedirects,
+	}
+	f.client = &http.Client{
+		// No proxy: addresses are checked as dialed
+		Transport: &http.Transport{
+			DialContext:         f.dialContext,
+			TLSHandshakeTimeout: dialTimeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		Timeout:       timeout,
+		CheckRedirect: f.checkRedirect,
+	}
+	return f
+}
+
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > f.MaxRedirects {
+		return ErrTooManyRedirects
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return ErrUnsupportedScheme
+	}
+	return nil
+}
+
+// Fetch downloads the page at rawurl and extracts its article, giving up
+// when ctx is done.
+func (f *Fetcher) Fetch(ctx context.Context, rawurl string) (*Article, error) {
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, ErrUnsupportedScheme
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.5")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// Report the fetcher's own refusals as such
+		if urlErr, ok := err.(*url.Error); ok {
+			switch urlErr.Err {
+			case ErrForbiddenAddress, ErrTooManyRedirects, ErrUnsupportedScheme:
+				return nil, urlErr.Err
+			}
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{URL: rawurl, Code: resp.StatusCode}
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !isHTML(contentType) {
+		return nil, ErrNotHTML
+	}
+	if resp.ContentLength > f.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	// Read one byte more than allowed to tell a page of exactly MaxSize
+	// from a larger one
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, f.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > f.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	a, err := Parse(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, err
+	}
+	a.URL = resp.Request.URL.String()
+	return a, nil
+}
+
+// isHTML accepts HTML pages and responses without a content type.
+func isHTML(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
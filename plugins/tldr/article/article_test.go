@@ -0,0 +1,220 @@
+package article
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		file        string
+		contentType string
+		title       string
+		byline      string
+		siteName    string
+		excerpt     string
+		published   time.Time
+		paragraphs  int
+		contains    []string
+		excludes    []string
+		err         error
+	}{
+		{
+			file:       "blog.html",
+			title:      "Go Concurrency Patterns: Context",
+			byline:     "Sameer Ajmani",
+			siteName:   "The Go Blog",
+			excerpt:    "How the context package carries deadlines and cancelation across API boundaries.",
+			published:  time.Date(2014, 7, 29, 9, 30, 0, 0, time.UTC),
+			paragraphs: 6,
+			contains: []string{
+				"In Go servers, each incoming request is handled in its own goroutine.",
+				"The Done method returns a channel",
+			},
+			excludes: []string{"Related articles", "Pipelines and cancellation", "Buy our product", "Copyright", "tracking", "Home"},
+		},
+		{
+			file:       "news.html",
+			title:      "City council approves the new riverside park plan",
+			byline:     "Jane Doe",
+			published:  time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC),
+			paragraphs: 3,
+			contains:   []string{"The city council voted on Tuesday", "Opponents of the plan"},
+			excludes:   []string{"Great news", "Sports", "By Jane Doe"},
+		},
+		{
+			file:        "windows1252.html",
+			contentType: "text/html",
+			title:       "Café culture",
+			paragraphs:  2,
+			contains:    []string{"“The café opened in 1923,” said the owner — and", "crème brûlée", "costs €2"},
+		},
+		{
+			file:       "utf16le.html",
+			title:      "Café culture",
+			paragraphs: 2,
+			contains:   []string{"“The café opened in 1923,”", "costs €2"},
+		},
+		{
+			file: "navigation.html",
+			err:  ErrNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		f, err := os.Open(filepath.Join("testdata", test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := Parse(f, test.contentType)
+		f.Close()
+		if err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.file, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if a.Title != test.title {
+			t.Errorf("%s: got title %q, want %q", test.file, a.Title, test.title)
+		}
+		if a.Byline != test.byline {
+			t.Errorf("%s: got byline %q, want %q", test.file, a.Byline, test.byline)
+		}
+		if a.SiteName != test.siteName {
+			t.Errorf("%s: got site name %q, want %q", test.file, a.SiteName, test.siteName)
+		}
+		if test.excerpt != "" && a.Excerpt != test.excerpt {
+			t.Errorf("%s: got excerpt %q, want %q", test.file, a.Excerpt, test.excerpt)
+		}
+		if !a.Published.Equal(test.published) {
+			t.Errorf("%s: got published %s, want %s", test.file, a.Published, test.published)
+		}
+		if len(a.Paragraphs) != test.paragraphs {
+			t.Errorf("%s: got %d paragraphs, want %d: %q", test.file, len(a.Paragraphs), test.paragraphs, a.Paragraphs)
+		}
+		text := a.Text()
+		for _, s := range test.contains {
+			if !strings.Contains(text, s) {
+				t.Errorf("%s: text lacks %q: %q", test.file, s, text)
+			}
+		}
+		for _, s := range test.excludes {
+			if strings.Contains(text, s) {
+				t.Errorf("%s: text contains %q: %q", test.file, s, text)
+			}
+		}
+	}
+}
+
+func TestCharset(t *testing.T) {
+	tests := []struct {
+		body        string
+		contentType string
+		charset     string
+		text        string
+	}{
+		{"\xef\xbb\xbfcaf\xc3\xa9", "text/html; charset=iso-8859-1", "utf-8", "café"},
+		{"\xfe\xff\x00c\x00a\x00f\x00\xe9", "", "utf-16be", "café"},
+		{"\xff\xfec\x00a\x00f\x00\xe9\x00", "text/html; charset=utf-8", "utf-16le", "café"},
+		{"caf\xe9", "text/html; charset=ISO-8859-1", "iso-8859-1", "café"},
+		{"\x93caf\xe9\x94 \x80", "text/html; charset=windows-1252", "windows-1252", "“café” €"},
+		{`<meta charset="windows-1252"><p>caf` + "\xe9", "text/html", "windows-1252", `<meta charset="windows-1252"><p>café`},
+		{`<meta http-equiv="Content-Type" content="text/html; charset=latin1">`, "", "latin1", `<meta http-equiv="Content-Type" content="text/html; charset=latin1">`},
+		{"caf\xc3\xa9", "", "", "café"},
+		{"caf\xe9", "", "", "café"},
+		{"caf\xe9", "text/html; charset=utf-8", "utf-8", "caf�"},
+	}
+
+	for _, test := range tests {
+		cs := charset([]byte(test.body), test.contentType)
+		if cs != test.charset {
+			t.Errorf("%q (%s): got charset %q, want %q", test.body, test.contentType, cs, test.charset)
+		}
+		text := decode([]byte(test.body), test.contentType)
+		if text != test.text {
+			t.Errorf("%q (%s): got text %q, want %q", test.body, test.contentType, text, test.text)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	page, err := ioutil.ReadFile(filepath.Join("testdata", "blog.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blog", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/blog", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/blog", http.StatusFound)
+	})
+	mux.HandleFunc("/report.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF-1.4"))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(page)
+		w.Write(page)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := NewFetcher(5 * time.Second)
+	fetcher.MaxSize = int64(len(page))
+	fetcher.allowPrivate = true
+
+	tests := []struct {
+		path string
+		url  string
+		err  error
+	}{
+		{path: "/blog", url: server.URL + "/blog"},
+		{path: "/moved", url: server.URL + "/blog"},
+		{path: "/missing", err: &StatusError{URL: server.URL + "/missing", Code: http.StatusNotFound}},
+		{path: "/loop", err: ErrTooManyRedirects},
+		{path: "/ftp", err: ErrUnsupportedScheme},
+		{path: "/report.pdf", err: ErrNotHTML},
+		{path: "/large", err: ErrTooLarge},
+	}
+
+	for _, test := range tests {
+		a, err := fetcher.Fetch(context.Background(), server.URL+test.path)
+		if fmt.Sprint(err) != fmt.Sprint(test.err) {
+			t.Errorf("%s: got error %v, want %v", test.path, err, test.err)
+			continue
+		}
+		if err == nil && a.URL != test.url {
+			t.Errorf("%s: got URL %q, want %q", test.path, a.URL, test.url)
+		}
+	}
+
+	// A new fetcher, as the previous one keeps its connections alive
+	fetcher = NewFetcher(5 * time.Second)
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/blog")
+	if err != ErrForbiddenAddress {
+		t.Errorf("got error %v fetching from a loopback address, want %v", err, ErrForbiddenAddress)
+	}
+	_, err = fetcher.Fetch(context.Background(), "file:///etc/passwd")
+	if err != ErrUnsupportedScheme {
+		t.Errorf("got error %v fetching a file URL, want %v", err, ErrUnsupportedScheme)
+	}
+}
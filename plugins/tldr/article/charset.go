@@ -0,0 +1,104 @@
+package article
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Only the start of a page is searched for a <meta> charset declaration
+const charsetSniffLength = 1024
+
+var metaCharsetRe = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_:.-]+)`)
+
+// Code points of windows-1252's 0x80-0x9f, where it differs from
+// ISO-8859-1. Browsers decode pages labelled latin1 or ASCII as
+// windows-1252 too.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+var windows1252Labels = set("windows-1252", "cp1252", "x-cp1252", "iso-8859-1",
+	"iso8859-1", "latin1", "l1", "iso_8859-1", "us-ascii", "ascii", "iso-8859-15", "latin9")
+
+// charset returns the encoding of body, from its byte order mark, the
+// Content-Type header or a <meta> declaration, in that order.
+func charset(body []byte, contentType string) string {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xef, 0xbb, 0xbf}):
+		return "utf-8"
+	case bytes.HasPrefix(body, []byte{0xfe, 0xff}):
+		return "utf-16be"
+	case bytes.HasPrefix(body, []byte{0xff, 0xfe}):
+		return "utf-16le"
+	}
+
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		return strings.ToLower(params["charset"])
+	}
+
+	head := body
+	if len(head) > charsetSniffLength {
+		head = head[:charsetSniffLength]
+	}
+	if m := metaCharsetRe.FindSubmatch(head); m != nil {
+		return strings.ToLower(string(m[1]))
+	}
+	return ""
+}
+
+// decode converts body to UTF-8. Pages with an unknown or missing charset
+// are taken as UTF-8 if they are valid UTF-8, windows-1252 otherwise.
+func decode(body []byte, contentType string) string {
+	cs := charset(body, contentType)
+	switch {
+	case cs == "utf-16be" || cs == "utf-16le" || cs == "utf-16":
+		return decodeUTF16(body, cs != "utf-16le")
+	case windows1252Labels[cs]:
+		return decodeWindows1252(body)
+	}
+
+	body = bytes.TrimPrefix(body, []byte{0xef, 0xbb, 0xbf})
+	if utf8.Valid(body) {
+		return string(body)
+	}
+	if cs == "" {
+		return decodeWindows1252(body)
+	}
+	// Declared UTF-8 (or unsupported) but not quite: keep what is valid
+	return string(bytes.Runes(body))
+}
+
+func decodeWindows1252(body []byte) string {
+	runes := make([]rune, len(body))
+	for i, c := range body {
+		if 0x80 <= c && c < 0xa0 {
+			runes[i] = windows1252[c-0x80]
+		} else {
+			runes[i] = rune(c)
+		}
+	}
+	return string(runes)
+}
+
+func decodeUTF16(body []byte, bigEndian bool) string {
+	if bytes.HasPrefix(body, []byte{0xfe, 0xff}) {
+		body, bigEndian = body[2:], true
+	} else if bytes.HasPrefix(body, []byte{0xff, 0xfe}) {
+		body, bigEndian = body[2:], false
+	}
+
+	units := make([]uint16, len(body)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(body[2*i])<<8 | uint16(body[2*i+1])
+		} else {
+			units[i] = uint16(body[2*i+1])<<8 | uint16(body[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,79 @@
+package article
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+const dialTimeout = 10 * time.Second
+
+// Networks pages may not be fetched from: loopback, private, shared,
+// link-local (which includes cloud metadata endpoints like
+// 169.254.169.254) and unspecified addresses.
+var forbiddenNets []*net.IPNet
+
+func init() {
+	for _, cidr := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"::/128",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+	} {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		forbiddenNets = append(forbiddenNets, ipNet)
+	}
+}
+
+func forbidden(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsMulticast() {
+		return true
+	}
+	for _, ipNet := range forbiddenNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialContext connects to one of addr's public addresses. The check is on
+// the resolved address actually dialed, so that it holds for redirects and
+// for names that resolve differently on a second lookup.
+func (f *Fetcher) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	err = ErrForbiddenAddress
+	for _, ip := range ips {
+		if forbidden(ip.IP) && !f.allowPrivate {
+			continue
+		}
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
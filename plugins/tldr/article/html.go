@@ -0,0 +1,369 @@
+package article
+
+import (
+	"html"
+	"strings"
+)
+
+type nodeType int
+
+const (
+	textNode nodeType = iota
+	elementNode
+)
+
+// node is an element or a run of text in the tree of a parsed page.
+type node struct {
+	typ      nodeType
+	tag      string
+	attrs    map[string]string
+	text     string
+	parent   *node
+	children []*node
+}
+
+func (n *node) attr(name string) string {
+	return n.attrs[name]
+}
+
+func (n *node) appendChild(c *node) {
+	c.parent = n
+	n.children = append(n.children, c)
+}
+
+// remove detaches n from its parent.
+func (n *node) remove() {
+	p := n.parent
+	if p == nil {
+		return
+	}
+	for i, c := range p.children {
+		if c == n {
+			p.children = append(p.children[:i], p.children[i+1:]...)
+			break
+		}
+	}
+	n.parent = nil
+}
+
+// walk calls fn for n and its descendants, depth first, skipping the
+// descendants of nodes for which fn returns false.
+func (n *node) walk(fn func(n *node) bool) {
+	if !fn(n) {
+		return
+	}
+	// Copy so that fn may remove nodes
+	children := append([]*node(nil), n.children...)
+	for _, c := range children {
+		c.walk(fn)
+	}
+}
+
+// find returns the elements with one of the given tags.
+func (n *node) find(tags ...string) []*node {
+	var found []*node
+	n.walk(func(c *node) bool {
+		if c.typ == elementNode {
+			for _, tag := range tags {
+				if c.tag == tag {
+					found = append(found, c)
+					break
+				}
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// innerText returns the text of n with whitespace collapsed.
+func (n *node) innerText() string {
+	var b []string
+	n.walk(func(c *node) bool {
+		if c.typ == textNode {
+			b = append(b, c.text)
+		} else if c.tag == "br" || blockTags[c.tag] {
+			b = append(b, " ")
+		}
+		return true
+	})
+	return collapse(strings.Join(b, ""))
+}
+
+func collapse(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+var (
+	voidTags = set("area", "base", "br", "col", "embed", "hr", "img", "input",
+		"link", "meta", "param", "source", "track", "wbr")
+
+	// Elements whose content is not markup
+	rawTextTags = set("script", "style", "title", "textarea", "xmp", "noembed", "noframes")
+
+	// Elements rendered as blocks, which also close an open paragraph
+	blockTags = set("address", "article", "aside", "blockquote", "dd", "details",
+		"div", "dl", "dt", "fieldset", "figcaption", "figure", "footer", "form",
+		"h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "li", "main", "nav",
+		"ol", "p", "pre", "section", "table", "tr", "td", "th", "ul")
+)
+
+func set(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+	return m
+}
+
+// treeBuilder turns a page into a tree of nodes. It is lenient the way
+// browsers are: unmatched end tags are ignored, unclosed elements are
+// closed by their parent's end tag and the usual implied end tags (of
+// paragraphs, list items, table cells...) are inserted.
+type treeBuilder struct {
+	root  *node
+	stack []*node
+}
+
+func (tb *treeBuilder) current() *node {
+	return tb.stack[len(tb.stack)-1]
+}
+
+func (tb *treeBuilder) addText(text string) {
+	if text == "" {
+		return
+	}
+	cur := tb.current()
+	if n := len(cur.children); n > 0 && cur.children[n-1].typ == textNode {
+		cur.children[n-1].text += text
+		return
+	}
+	cur.appendChild(&node{typ: textNode, text: text})
+}
+
+// closeUntil pops the stack up to and including the innermost element
+// with one of tags, unless an element with one of boundaries comes first.
+func (tb *treeBuilder) closeUntil(tags, boundaries map[string]bool) {
+	for i := len(tb.stack) - 1; i > 0; i-- {
+		tag := tb.stack[i].tag
+		if tags[tag] {
+			tb.stack = tb.stack[:i]
+			return
+		}
+		if boundaries[tag] {
+			return
+		}
+	}
+}
+
+var (
+	listItemTags = set("li")
+	listTags     = set("ul", "ol", "menu")
+	defTags      = set("dt", "dd")
+	defListTags  = set("dl")
+	cellTags     = set("td", "th")
+	rowTags      = set("tr")
+	tableTags    = set("table", "tbody", "thead", "tfoot")
+	optionTags   = set("option")
+	selectTags   = set("select", "datalist")
+	paraTags     = set("p")
+)
+
+func (tb *treeBuilder) open(tag string, attrs map[string]string, selfClosing bool) *node {
+	switch tag {
+	case "li":
+		tb.closeUntil(listItemTags, listTags)
+	case "dt", "dd":
+		tb.closeUntil(defTags, defListTags)
+	case "td", "th":
+		tb.closeUntil(cellTags, rowTags)
+	case "tr":
+		tb.closeUntil(rowTags, tableTags)
+	case "option":
+		tb.closeUntil(optionTags, selectTags)
+	}
+	if blockTags[tag] && tb.current().tag == "p" {
+		tb.stack = tb.stack[:len(tb.stack)-1]
+	}
+
+	n := &node{typ: elementNode, tag: tag, attrs: attrs}
+	tb.current().appendChild(n)
+	if !voidTags[tag] && !selfClosing {
+		tb.stack = append(tb.stack, n)
+	}
+	return n
+}
+
+func (tb *treeBuilder) close(tag string) {
+	tb.closeUntil(set(tag), nil)
+}
+
+// parseHTML parses page into a tree rooted at a "#document" node.
+func parseHTML(page string) *node {
+	tb := &treeBuilder{root: &node{typ: elementNode, tag: "#document"}}
+	tb.stack = []*node{tb.root}
+
+	i := 0
+	for i < len(page) {
+		lt := strings.IndexByte(page[i:], '<')
+		if lt < 0 {
+			tb.addText(html.UnescapeString(page[i:]))
+			break
+		}
+		tb.addText(html.UnescapeString(page[i : i+lt]))
+		i += lt
+		rest := page[i:]
+
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				i = len(page)
+			} else {
+				i += 4 + end + 3
+			}
+
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				i = len(page)
+			} else {
+				i += end + 1
+			}
+
+		case strings.HasPrefix(rest, "</") && len(rest) > 2 && isLetter(rest[2]):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			tag, _ := readName(rest[2:end])
+			tb.close(tag)
+			i += end + 1
+
+		case len(rest) > 1 && isLetter(rest[1]):
+			tag, attrs, selfClosing, n := parseStartTag(rest)
+			i += n
+			el := tb.open(tag, attrs, selfClosing)
+			if !rawTextTags[tag] || selfClosing {
+				continue
+			}
+
+			// Raw text runs up to the matching end tag
+			end := indexFold(page[i:], "</"+tag)
+			if end < 0 {
+				end = len(page) - i
+			}
+			text := page[i : i+end]
+			if tag == "title" || tag == "textarea" {
+				text = html.UnescapeString(text)
+			}
+			if text != "" {
+				el.appendChild(&node{typ: textNode, text: text})
+			}
+			tb.close(tag)
+			i += end
+			if gt := strings.IndexByte(page[i:], '>'); gt >= 0 {
+				i += gt + 1
+			} else {
+				i = len(page)
+			}
+
+		default:
+			tb.addText("<")
+			i++
+		}
+	}
+	return tb.root
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// readName reads a lower-cased tag or attribute name at the start of s.
+func readName(s string) (string, int) {
+	n := 0
+	for n < len(s) && !isSpace(s[n]) && s[n] != '/' && s[n] != '>' && s[n] != '=' {
+		n++
+	}
+	return strings.ToLower(s[:n]), n
+}
+
+// parseStartTag parses the start tag at the start of s and returns its
+// name, attributes, whether it is self-closing and its length.
+func parseStartTag(s string) (string, map[string]string, bool, int) {
+	tag, i := readName(s[1:])
+	i++
+	attrs := make(map[string]string)
+
+	for i < len(s) {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return tag, attrs, false, i + 1
+		}
+		if strings.HasPrefix(s[i:], "/>") {
+			return tag, attrs, true, i + 2
+		}
+		if s[i] == '/' {
+			i++
+			continue
+		}
+
+		name, n := readName(s[i:])
+		if n == 0 {
+			// A stray "=": skip it
+			i++
+			continue
+		}
+		i += n
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					end = len(s) - i - 1
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+		if _, dup := attrs[name]; !dup {
+			attrs[name] = html.UnescapeString(value)
+		}
+	}
+	return tag, attrs, false, len(s)
+}
+
+// indexFold is strings.Index ignoring ASCII case.
+func indexFold(s, substr string) int {
+	n := len(substr)
+	for i := 0; i+n <= len(s); i++ {
+		if strings.EqualFold(s[i:i+n], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,155 @@
+package article
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	bylineRe       = regexp.MustCompile(`(?i)byline|author|writtenby|p-author`)
+	bylinePrefixRe = regexp.MustCompile(`(?i)^(by|von|par|por)\s+`)
+
+	// Separators between a page's title and the site's name
+	titleSeparators = []string{" | ", " - ", " – ", " — ", " :: ", " / ", " » "}
+
+	dateLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05Z0700",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		time.RFC1123Z,
+		time.RFC1123,
+		"January 2, 2006",
+		"2 January 2006",
+	}
+)
+
+// metadata collects the <meta> tags of a page by lower-cased property,
+// name or itemprop, first one wins.
+func metadata(doc *node) map[string]string {
+	meta := make(map[string]string)
+	for _, n := range doc.find("meta") {
+		content := collapse(n.attr("content"))
+		if content == "" {
+			continue
+		}
+		for _, key := range []string{n.attr("property"), n.attr("name"), n.attr("itemprop")} {
+			// property may list several keys, e.g. "og:title twitter:title"
+			for _, k := range strings.Fields(strings.ToLower(key)) {
+				if _, ok := meta[k]; !ok {
+					meta[k] = content
+				}
+			}
+		}
+	}
+	return meta
+}
+
+func first(meta map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if v := meta[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func title(doc *node, meta map[string]string, siteName string) string {
+	if t := first(meta, "og:title", "twitter:title", "dc.title", "dcterm:title", "parsely-title"); t != "" {
+		return t
+	}
+
+	var t string
+	if titles := doc.find("title"); len(titles) > 0 {
+		t = titles[0].innerText()
+	}
+	if t == "" {
+		if h1s := doc.find("h1"); len(h1s) > 0 {
+			return h1s[0].innerText()
+		}
+		return ""
+	}
+
+	// Drop the site's name from "Title | Site", unless that leaves too
+	// little of a title
+	for _, sep := range titleSeparators {
+		i := strings.LastIndex(t, sep)
+		if i < 0 {
+			continue
+		}
+		head, tail := strings.TrimSpace(t[:i]), strings.TrimSpace(t[i+len(sep):])
+		if siteName != "" && strings.EqualFold(head, siteName) {
+			return tail
+		}
+		if len(strings.Fields(head)) >= 3 {
+			return head
+		}
+	}
+	return t
+}
+
+func byline(doc *node, meta map[string]string) string {
+	author := first(meta, "author", "article:author", "dc.creator", "dcterm:creator",
+		"parsely-author", "sailthru.author", "byl")
+	// article:author is often a link to the author's page
+	if author != "" && !strings.HasPrefix(author, "http") {
+		return cleanByline(author)
+	}
+
+	var found string
+	doc.walk(func(n *node) bool {
+		if found != "" {
+			return false
+		}
+		if n.typ != elementNode {
+			return true
+		}
+		if n.attr("rel") == "author" || n.attr("itemprop") == "author" ||
+			bylineRe.MatchString(n.attr("class")+" "+n.attr("id")) {
+			text := n.innerText()
+			if text != "" && len(text) < 100 {
+				found = cleanByline(text)
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+func cleanByline(s string) string {
+	return bylinePrefixRe.ReplaceAllString(collapse(s), "")
+}
+
+func published(doc *node, meta map[string]string) time.Time {
+	candidates := []string{first(meta, "article:published_time", "og:article:published_time",
+		"datepublished", "date", "pubdate", "publishdate", "dc.date", "dc.date.issued",
+		"dcterm:created", "parsely-pub-date", "sailthru.date")}
+	// Then <time> elements, those marked as the publishing date first
+	var others []string
+	for _, n := range doc.find("time") {
+		if _, ok := n.attrs["pubdate"]; ok || n.attr("itemprop") == "datePublished" {
+			candidates = append(candidates, n.attr("datetime"))
+		} else {
+			others = append(others, n.attr("datetime"))
+		}
+	}
+	candidates = append(candidates, others...)
+
+	for _, c := range candidates {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		for _, layout := range dateLayouts {
+			t, err := time.Parse(layout, c)
+			if err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
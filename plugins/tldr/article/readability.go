@@ -0,0 +1,267 @@
+package article
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Content extraction follows the heuristics of Arc90's Readability: score
+// paragraphs by length and commas, credit their ancestors, penalize links
+// and boilerplate class names, then take the best scoring element with
+// its related siblings.
+
+const (
+	minParagraphLength = 25
+	minSiblingScore    = 10
+)
+
+var (
+	// Elements never part of the article
+	stripTags = set("script", "style", "noscript", "iframe", "object", "embed",
+		"form", "button", "input", "select", "textarea", "nav", "footer", "aside",
+		"svg", "canvas", "template", "link", "meta", "title")
+
+	unlikelyRe = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote|cookie|newsletter|subscribe|share`)
+	maybeRe    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveRe = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativeRe = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+
+	// Elements whose text is scored as a paragraph
+	scoredTags = set("p", "pre", "td", "blockquote", "section", "h2", "h3", "h4", "h5", "h6")
+
+	// Elements that are one paragraph of content
+	paragraphTags = set("p", "pre", "li", "blockquote", "h2", "h3", "h4", "h5", "h6",
+		"figcaption", "dt", "dd", "td", "th")
+
+	inlineTags = set("a", "abbr", "b", "bdi", "bdo", "cite", "code", "data", "dfn",
+		"em", "font", "i", "kbd", "mark", "q", "s", "samp", "small", "span",
+		"strong", "sub", "sup", "time", "u", "var", "img")
+)
+
+// prepare drops elements that cannot be part of the article: scripts,
+// forms, navigation, hidden elements and those with boilerplate class
+// names.
+func prepare(doc *node) {
+	doc.walk(func(n *node) bool {
+		if n.typ != elementNode {
+			return true
+		}
+		if stripTags[n.tag] || isHidden(n) {
+			n.remove()
+			return false
+		}
+		switch n.tag {
+		case "#document", "html", "body", "article", "main", "a":
+			return true
+		}
+		match := n.attr("class") + " " + n.attr("id") + " " + n.attr("role")
+		if unlikelyRe.MatchString(match) && !maybeRe.MatchString(match) {
+			n.remove()
+			return false
+		}
+		return true
+	})
+}
+
+func isHidden(n *node) bool {
+	if _, ok := n.attrs["hidden"]; ok {
+		return true
+	}
+	style := strings.Replace(strings.ToLower(n.attr("style")), " ", "", -1)
+	return n.attr("aria-hidden") == "true" ||
+		strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+func classWeight(n *node) float64 {
+	weight := 0.0
+	for _, s := range []string{n.attr("class"), n.attr("id")} {
+		if s == "" {
+			continue
+		}
+		if negativeRe.MatchString(s) {
+			weight -= 25
+		}
+		if positiveRe.MatchString(s) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+func tagWeight(n *node) float64 {
+	switch n.tag {
+	case "article":
+		return 10
+	case "div", "main":
+		return 5
+	case "pre", "td", "blockquote":
+		return 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		return -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		return -5
+	}
+	return 0
+}
+
+// linkDensity is the share of n's text that is link text.
+func linkDensity(n *node) float64 {
+	text := len(n.innerText())
+	if text == 0 {
+		return 0
+	}
+	links := 0
+	for _, a := range n.find("a") {
+		links += len(a.innerText())
+	}
+	return float64(links) / float64(text)
+}
+
+// hasBlockChildren reports whether n contains block elements, i.e. is not
+// a div used as a paragraph.
+func hasBlockChildren(n *node) bool {
+	found := false
+	n.walk(func(c *node) bool {
+		if c != n && c.typ == elementNode && blockTags[c.tag] {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// topCandidate returns the element most likely to hold the article's
+// content, and the scores of all candidates.
+func topCandidate(doc *node) (*node, map[*node]float64) {
+	scores := make(map[*node]float64)
+	var candidates []*node
+
+	doc.walk(func(n *node) bool {
+		if n.typ != elementNode {
+			return true
+		}
+		if !scoredTags[n.tag] && !(n.tag == "div" && !hasBlockChildren(n)) {
+			return true
+		}
+		text := n.innerText()
+		if len(text) < minParagraphLength {
+			return true
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text)/100), 3)
+		// Credit the parent fully, the grandparent by half and the next
+		// ancestors less and less
+		level := 0
+		for a := n.parent; a != nil && a.tag != "#document" && level < 5; a = a.parent {
+			if _, ok := scores[a]; !ok {
+				scores[a] = tagWeight(a) + classWeight(a)
+				candidates = append(candidates, a)
+			}
+			divider := 1.0
+			if level == 1 {
+				divider = 2
+			} else if level > 1 {
+				divider = float64(level * 3)
+			}
+			scores[a] += score / divider
+			level++
+		}
+		return true
+	})
+
+	var top *node
+	for _, c := range candidates {
+		scores[c] *= 1 - linkDensity(c)
+		if top == nil || scores[c] > scores[top] {
+			top = c
+		}
+	}
+	return top, scores
+}
+
+// content returns the paragraphs of the article in doc.
+func content(doc *node) []string {
+	prepare(doc)
+
+	top, scores := topCandidate(doc)
+	if top == nil {
+		bodies := doc.find("body")
+		if len(bodies) == 0 {
+			return paragraphs(doc)
+		}
+		return paragraphs(bodies[0])
+	}
+	if top.parent == nil {
+		return paragraphs(top)
+	}
+
+	// Related siblings, e.g. paragraphs split into several divs, belong
+	// to the article too
+	threshold := math.Max(minSiblingScore, scores[top]*0.2)
+	var ps []string
+	for _, sibling := range top.parent.children {
+		include := sibling == top
+		if !include && sibling.typ == elementNode {
+			score, ok := scores[sibling]
+			if ok && sibling.attr("class") != "" && sibling.attr("class") == top.attr("class") {
+				score += scores[top] * 0.2
+			}
+			if ok && score >= threshold {
+				include = true
+			} else if sibling.tag == "p" {
+				text := sibling.innerText()
+				density := linkDensity(sibling)
+				include = len(text) > 80 && density < 0.25 ||
+					len(text) > 0 && density == 0 && strings.Contains(text, ". ")
+			}
+		}
+		if include {
+			ps = append(ps, paragraphs(sibling)...)
+		}
+	}
+	return ps
+}
+
+// paragraphs splits the text of n into paragraphs at block elements.
+func paragraphs(n *node) []string {
+	var ps []string
+	var inline []string
+	flush := func() {
+		if text := collapse(strings.Join(inline, "")); text != "" {
+			ps = append(ps, text)
+		}
+		inline = inline[:0]
+	}
+
+	var visit func(c *node)
+	visit = func(c *node) {
+		switch {
+		case c.typ == textNode:
+			inline = append(inline, c.text)
+		case c.tag == "br":
+			flush()
+		case c.tag == "h1":
+			// Usually the title, which is reported on its own
+			flush()
+		case paragraphTags[c.tag] && !hasBlockChildren(c):
+			flush()
+			if text := c.innerText(); text != "" {
+				ps = append(ps, text)
+			}
+		case inlineTags[c.tag]:
+			for _, child := range c.children {
+				visit(child)
+			}
+		default:
+			flush()
+			for _, child := range c.children {
+				visit(child)
+			}
+			flush()
+		}
+	}
+	visit(n)
+	flush()
+	return ps
+}
@@ -0,0 +1,230 @@
+package tldr
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dysfn/wasb/plugins/tldr/article"
+	"github.com/dysfn/wasb/wasb"
+)
+
+const (
+	cacheNamespace = "tldr.summaries"
+
+	defaultCacheTTL        = 24 * time.Hour
+	defaultCacheMaxEntries = 1000
+)
+
+// Metrics, published at /debug/vars on the debug address
+var metrics = expvar.NewMap("tldr")
+
+type CacheCfg struct {
+	// Seconds a summary is kept, a day if zero
+	TTL int `json:"ttl"`
+
+	// Number of summaries kept, 1000 if zero
+	MaxEntries int `json:"maxentries"`
+
+	// Keep summaries in the workspace's store, so that they survive
+	// restarts if it is persisted to a file
+	Persist bool `json:"persist"`
+}
+
+type cacheEntry struct {
+	key     string
+	summary *Summary
+	expires time.Time
+}
+
+// Cache keeps the most recently used summaries for a while, optionally
+// persisting them to a store.
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries *list.List
+	keys    map[string]*list.Element
+	store   wasb.Store
+	log     *log.Logger
+}
+
+// NewCache returns a cache of maxEntries summaries kept for ttl. If store
+// is not nil, summaries are persisted to it and the ones it already holds
+// are loaded.
+func NewCache(ttl time.Duration, maxEntries int, store wasb.Store, logger *log.Logger) *Cache {
+	c := &Cache{
+		ttl:     ttl,
+		max:     maxEntries,
+		entries: list.New(),
+		keys:    make(map[string]*list.Element),
+		store:   store,
+		log:     logger,
+	}
+	if store != nil {
+		c.load()
+	}
+	return c
+}
+
+func newCache(cfg *CacheCfg, store wasb.Store, logger *log.Logger) *Cache {
+	ttl, max := defaultCacheTTL, defaultCacheMaxEntries
+	if cfg == nil {
+		return NewCache(ttl, max, nil, logger)
+	}
+	if cfg.TTL > 0 {
+		ttl = time.Duration(cfg.TTL) * time.Second
+	}
+	if cfg.MaxEntries > 0 {
+		max = cfg.MaxEntries
+	}
+	if !cfg.Persist {
+		store = nil
+	}
+	return NewCache(ttl, max, store, logger)
+}
+
+type persistedSummary struct {
+	Summary *Summary  `json:"summary"`
+	Expires time.Time `json:"expires"`
+}
+
+func (c *Cache) load() {
+	keys, err := c.store.List(cacheNamespace, "")
+	if err != nil {
+		c.log.Printf("Error loading summary cache: %s", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, key := range keys {
+		value, err := c.store.Get(cacheNamespace, key)
+		if err != nil {
+			continue
+		}
+		var p persistedSummary
+		err = json.Unmarshal(value, &p)
+		if err != nil || p.Summary == nil || !p.Expires.After(now) {
+			c.store.Delete(cacheNamespace, key)
+			continue
+		}
+		c.add(key, p.Summary, p.Expires)
+	}
+}
+
+// cacheKey identifies a summary by URL and the options changing its
+// content. The format only changes its layout.
+func cacheKey(url string, opts *Options) string {
+	return fmt.Sprintf("%d:%d:%s:%s", opts.Length, opts.Keywords, opts.Language, normalizeURL(url))
+}
+
+func (c *Cache) Get(url string, opts *Options) (*Summary, bool) {
+	key := cacheKey(url, opts)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.keys[key]
+	if !ok {
+		metrics.Add("cachemisses", 1)
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if !time.Now().Before(e.expires) {
+		c.remove(el)
+		metrics.Add("cachemisses", 1)
+		return nil, false
+	}
+	c.entries.MoveToFront(el)
+	metrics.Add("cachehits", 1)
+	return e.summary, true
+}
+
+func (c *Cache) Add(url string, opts *Options, summary *Summary) {
+	key := cacheKey(url, opts)
+	expires := time.Now().Add(c.ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.add(key, summary, expires)
+
+	if c.store != nil {
+		value, err := json.Marshal(&persistedSummary{Summary: summary, Expires: expires})
+		if err == nil {
+			err = c.store.Set(cacheNamespace, key, value, c.ttl)
+		}
+		if err != nil {
+			c.log.Printf("Error persisting summary (key: %s): %s", key, err)
+		}
+	}
+}
+
+// add inserts or refreshes an entry, evicting the least recently used ones
+// over the size bound.
+func (c *Cache) add(key string, summary *Summary, expires time.Time) {
+	if el, ok := c.keys[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.summary, e.expires = summary, expires
+		c.entries.MoveToFront(el)
+		return
+	}
+	c.keys[key] = c.entries.PushFront(&cacheEntry{key: key, summary: summary, expires: expires})
+
+	for c.entries.Len() > c.max {
+		c.remove(c.entries.Back())
+		metrics.Add("cacheevictions", 1)
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.entries.Remove(el).(*cacheEntry)
+	delete(c.keys, e.key)
+	if c.store != nil {
+		c.store.Delete(cacheNamespace, e.key)
+	}
+}
+
+// cachedSummarizer only asks its summarizer for summaries not in the
+// cache.
+type cachedSummarizer struct {
+	Summarizer
+	cache *Cache
+}
+
+func (s *cachedSummarizer) Summarize(ctx context.Context, url string, opts *Options) (*Summary, error) {
+	if summary, ok := s.cache.Get(url, opts); ok {
+		return summary, nil
+	}
+	summary, err := s.Summarizer.Summarize(ctx, url, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Add(url, opts, summary)
+	return summary, nil
+}
+
+// SummarizeArticle only caches: callers look the summary up before
+// fetching a.
+func (s *cachedSummarizer) SummarizeArticle(ctx context.Context, url string, a *article.Article, opts *Options) (*Summary, error) {
+	summary, err := summarizeArticle(ctx, s.Summarizer, url, a, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.Add(url, opts, summary)
+	return summary, nil
+}
+
+// SummarizeText is not cached: conversations change.
+func (s *cachedSummarizer) SummarizeText(ctx context.Context, text string, opts *Options) (*Summary, error) {
+	ts, ok := s.Summarizer.(TextSummarizer)
+	if !ok {
+		return nil, ErrTextUnsupported
+	}
+	return ts.SummarizeText(ctx, text, opts)
+}
@@ -0,0 +1,57 @@
+package tldr
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/dysfn/wasb/plugins/tldr/article"
+)
+
+// ExtractiveSummarizer summarizes pages without any third-party API: it
+// fetches the page, extracts the article and picks its highest ranked
+// sentences with TextRank.
+type ExtractiveSummarizer struct {
+	fetcher *article.Fetcher
+}
+
+func newExtractiveSummarizer(cfg *ProviderCfg, logger *log.Logger) (Summarizer, error) {
+	return &ExtractiveSummarizer{
+		fetcher: article.NewFetcher(cfg.timeout()),
+	}, nil
+}
+
+func (s *ExtractiveSummarizer) Summarize(ctx context.Context, url string, opts *Options) (*Summary, error) {
+	a, err := s.fetcher.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return s.SummarizeArticle(ctx, url, a, opts)
+}
+
+func (s *ExtractiveSummarizer) SummarizeArticle(ctx context.Context, url string, a *article.Article, opts *Options) (*Summary, error) {
+	summary, err := summarizeParagraphs(a.Paragraphs, opts)
+	if err != nil {
+		return nil, err
+	}
+	summary.Title = a.Title
+	return summary, nil
+}
+
+// SummarizeText summarizes text with one paragraph per line, e.g. one
+// message per line of a conversation.
+func (s *ExtractiveSummarizer) SummarizeText(ctx context.Context, text string, opts *Options) (*Summary, error) {
+	return summarizeParagraphs(strings.Split(text, "\n"), opts)
+}
+
+func summarizeParagraphs(paragraphs []string, opts *Options) (*Summary, error) {
+	sentences := extract(paragraphs, opts.Length)
+	if len(sentences) == 0 {
+		return nil, ErrNoSummary
+	}
+	return &Summary{
+		Content:   strings.Join(sentences, " "),
+		Sentences: sentences,
+		Keywords:  keywords(paragraphs, opts.Keywords),
+	}, nil
+}
@@ -0,0 +1,280 @@
+package tldr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dysfn/wasb/wasb"
+	"github.com/dysfn/wasb/wasb/mrkdwn"
+)
+
+const (
+	defaultHistoryLimit = 500
+	maxHistoryAge       = 7 * 24 * time.Hour
+)
+
+var (
+	thisThreadRe = regexp.MustCompile(`(?i)\bthis thread\b`)
+	lastRe       = regexp.MustCompile(`(?i)\blast\s+(\d+)\s*(m|mins?|minutes?|h|hrs?|hours?|d|days?)\b`)
+)
+
+// Messages worth summarizing, by subtype
+var historySubtypes = map[string]bool{
+	"":                 true,
+	"thread_broadcast": true,
+	"file_share":       true,
+	"me_message":       true,
+	"bot_message":      true,
+}
+
+// historyRequest is "@tldr this thread" or "@tldr last 2h [in #channel]".
+type historyRequest struct {
+	thread  bool
+	channel string
+	since   time.Duration
+}
+
+func parseHistoryRequest(m *wasb.Msg) (*historyRequest, bool) {
+	text := mrkdwn.Plain(mrkdwn.Parse(m.Text))
+	if thisThreadRe.MatchString(text) {
+		return &historyRequest{thread: true, channel: m.Channel}, true
+	}
+
+	match := lastRe.FindStringSubmatch(text)
+	if match == nil {
+		return nil, false
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, false
+	}
+	unit := time.Minute
+	switch strings.ToLower(match[2])[0] {
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	}
+
+	req := &historyRequest{channel: m.Channel, since: time.Duration(n) * unit}
+	for _, t := range mrkdwn.Parse(m.Text) {
+		if t.Kind == mrkdwn.ChannelToken {
+			req.channel = t.Value
+			break
+		}
+	}
+	return req, true
+}
+
+func (req *historyRequest) describe() string {
+	if req.thread {
+		return "this thread"
+	}
+	return fmt.Sprintf("the last %s in %s", formatDuration(req.since), mrkdwn.Channel(req.channel))
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
+
+func (bot *TLDR) isHistoryRequest(m *wasb.Msg) bool {
+	if m.Type != "message" || !mentions(m.Text, bot.self.ID) {
+		return false
+	}
+	_, ok := parseHistoryRequest(m)
+	return ok
+}
+
+// users resolves user IDs to display names, caching them.
+type users struct {
+	api   *wasb.API
+	mu    sync.Mutex
+	names map[string]string
+}
+
+func (u *users) name(ctx context.Context, id string) string {
+	u.mu.Lock()
+	name, ok := u.names[id]
+	u.mu.Unlock()
+	if ok {
+		return name
+	}
+
+	user, err := u.api.WithContext(ctx).UserInfo(id)
+	if err != nil {
+		return id
+	}
+	name = user.DisplayName()
+
+	u.mu.Lock()
+	if u.names == nil {
+		u.names = make(map[string]string)
+	}
+	u.names[id] = name
+	u.mu.Unlock()
+	return name
+}
+
+// render turns messages into text, one "name: message" line each, oldest
+// first, with mentions resolved to names.
+func (u *users) render(ctx context.Context, msgs []*wasb.Msg, skip func(m *wasb.Msg) bool) (string, int) {
+	var b bytes.Buffer
+	n := 0
+	for _, m := range msgs {
+		if skip(m) || !historySubtypes[m.Subtype] {
+			continue
+		}
+		tokens := mrkdwn.Parse(m.Text)
+		for i, t := range tokens {
+			if t.Kind == mrkdwn.UserToken && t.Label == "" {
+				tokens[i].Label = u.name(ctx, t.Value)
+			}
+		}
+		text := strings.Join(strings.Fields(mrkdwn.Plain(tokens)), " ")
+		if text == "" {
+			continue
+		}
+
+		author := "bot"
+		if m.User != "" {
+			author = u.name(ctx, m.User)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", author, text)
+		n++
+	}
+	return b.String(), n
+}
+
+func (bot *TLDR) fetchHistory(req *historyRequest, m *wasb.Msg) ([]*wasb.Msg, error) {
+	api := bot.api.WithContext(m.Context())
+	if req.thread {
+		return api.ConversationReplies(req.channel, m.ThreadTS, bot.historyLimit)
+	}
+
+	oldest := strconv.FormatInt(time.Now().Add(-req.since).Unix(), 10) + ".000000"
+	msgs, err := api.ConversationHistory(req.channel, oldest, bot.historyLimit)
+	if err != nil {
+		return nil, err
+	}
+	// History comes newest first
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs, nil
+}
+
+// isMember reports whether user may read channel's history, so that the
+// bot does not leak conversations of channels the user is not in.
+func (bot *TLDR) isMember(ctx context.Context, channel, user string) (bool, error) {
+	members, err := bot.api.WithContext(ctx).ConversationMembers(channel)
+	if err != nil {
+		return false, err
+	}
+	for _, member := range members {
+		if member == user {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (bot *TLDR) summarizeHistory(m *wasb.Msg) error {
+	req, _ := parseHistoryRequest(m)
+	opts, err := parseOptions(m.Text, bot.defaults, bot.maxLength)
+	if err != nil {
+		return bot.replier.ReplyText(m, err.Error())
+	}
+
+	switch {
+	case req.thread && m.ThreadTS == "":
+		return bot.replier.ReplyText(m, "Ask me in a thread to summarize it.")
+	case !req.thread && (req.since <= 0 || req.since > maxHistoryAge):
+		return bot.replier.ReplyText(m, fmt.Sprintf("I can only summarize up to the last %s.", formatDuration(maxHistoryAge)))
+	}
+
+	// Another channel's conversation is only shown to whoever asked, who
+	// may read it: the channel asked in may have other members
+	ctx := m.Context()
+	private := req.channel != m.Channel
+	reply := func(resp *wasb.Msg) error {
+		if !private {
+			return bot.replier.Reply(m, resp)
+		}
+		resp.Channel, resp.ThreadTS = m.Channel, m.ThreadTS
+		return bot.api.WithContext(ctx).PostEphemeral(resp, m.User)
+	}
+	replyText := func(text string) error {
+		return reply(&wasb.Msg{Type: "message", Text: text})
+	}
+
+	if private {
+		ok, err := bot.isMember(ctx, req.channel, m.User)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return bot.replier.ReplyText(m, "You can only summarize channels you are a member of.")
+		}
+	}
+
+	msgs, err := bot.fetchHistory(req, m)
+	if err != nil {
+		if apiErr, ok := err.(*wasb.APIError); ok && apiErr.Code == "not_in_channel" {
+			return bot.replier.ReplyText(m, "I need to be in "+mrkdwn.Channel(req.channel)+" to read it.")
+		}
+		if timedOut(err) {
+			return bot.replier.ReplyText(m, timeoutReply)
+		}
+		return err
+	}
+
+	text, n := bot.users.render(ctx, msgs, func(h *wasb.Msg) bool {
+		// Leave out the bot's own messages and the request itself
+		return h.User == bot.self.ID || h.TS == m.TS
+	})
+	if n == 0 {
+		return replyText("There is nothing to summarize in " + req.describe() + ".")
+	}
+
+	summarizer, ok := bot.summarizer.(TextSummarizer)
+	if !ok {
+		return ErrTextUnsupported
+	}
+	summary, err := summarizer.SummarizeText(ctx, text, opts)
+	if err != nil {
+		bot.log.Printf("Error summarizing history (channel: %s): %s", req.channel, err)
+		return replyText(errorReply(err))
+	}
+
+	// describe is already mrkdwn
+	title := fmt.Sprintf("Summary of %s (%d messages)", req.describe(), n)
+	resp := &wasb.Msg{
+		Type:   "message",
+		Text:   "*" + title + "*\n" + summary.Mrkdwn(opts.Format),
+		Blocks: historyCard(title, summary, opts.Format),
+	}
+	return reply(resp)
+}
+
+func historyCard(title string, summary *Summary, format Format) wasb.Blocks {
+	blocks := wasb.Blocks{
+		wasb.Section(wasb.Markdown("*" + title + "*")),
+		wasb.Section(wasb.Markdown(summary.Mrkdwn(format))),
+	}
+	if blocks.Validate() != nil {
+		return nil
+	}
+	return blocks
+}
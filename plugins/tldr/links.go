@@ -0,0 +1,115 @@
+package tldr
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/dysfn/wasb/wasb/mrkdwn"
+)
+
+const (
+	defaultMaxLinks    = 5
+	defaultConcurrency = 3
+)
+
+// URLs not wrapped in Slack's <url|label> markup, e.g. in slash command
+// text
+var bareURLRe = regexp.MustCompile(`https?://[^\s<>|]+[^\s<>|.,;:!?)\]'"]`)
+
+type link struct {
+	URL   string
+	Label string
+}
+
+// links returns the distinct web links of text, at most max of them, in
+// order of appearance.
+func links(text string, max int) []*link {
+	var ls []*link
+	seen := make(map[string]bool)
+	add := func(url, label string) {
+		key := normalizeURL(url)
+		if seen[key] || len(ls) >= max {
+			return
+		}
+		seen[key] = true
+		ls = append(ls, &link{URL: url, Label: label})
+	}
+
+	for _, t := range mrkdwn.Parse(text) {
+		switch t.Kind {
+		case mrkdwn.LinkToken:
+			if strings.HasPrefix(t.Value, "http://") || strings.HasPrefix(t.Value, "https://") {
+				add(t.Value, t.Label)
+			}
+		case mrkdwn.TextToken:
+			for _, url := range bareURLRe.FindAllString(t.Value, -1) {
+				add(url, "")
+			}
+		}
+	}
+	return ls
+}
+
+// mentions reports whether text mentions the user.
+func mentions(text, user string) bool {
+	for _, t := range mrkdwn.Parse(text) {
+		if t.Kind == mrkdwn.UserToken && t.Value == user {
+			return true
+		}
+	}
+	return false
+}
+
+type result struct {
+	link    *link
+	summary *Summary
+	err     error
+}
+
+// label names a result by its link's label or the page's title, if any.
+func (r *result) label() string {
+	switch {
+	case r.link.Label != "":
+		return r.link.Label
+	case r.summary != nil && r.summary.Title != "":
+		return r.summary.Title
+	}
+	return ""
+}
+
+// summarizeAll summarizes links in parallel, at most bot.concurrency at a
+// time, and returns the results in the links' order. Links still waiting
+// when ctx is done fail with its error.
+func (bot *TLDR) summarizeAll(ctx context.Context, ls []*link, opts *Options) []*result {
+	return bot.eachLink(ctx, ls, func(ctx context.Context, l *link) (*Summary, error) {
+		return bot.summarize(ctx, l.URL, opts)
+	})
+}
+
+// eachLink calls fn on links in parallel, at most bot.concurrency at a
+// time, as summarizeAll.
+func (bot *TLDR) eachLink(ctx context.Context, ls []*link, fn func(ctx context.Context, l *link) (*Summary, error)) []*result {
+	results := make([]*result, len(ls))
+	sem := make(chan struct{}, bot.concurrency)
+	var wg sync.WaitGroup
+	for i, l := range ls {
+		wg.Add(1)
+		go func(i int, l *link) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = &result{link: l, err: ctx.Err()}
+				return
+			}
+			defer func() { <-sem }()
+
+			summary, err := fn(ctx, l)
+			results[i] = &result{link: l, summary: summary, err: err}
+		}(i, l)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,110 @@
+package tldr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dysfn/wasb/wasb/mrkdwn"
+)
+
+const (
+	minSummaryLength        = 1
+	defaultMaxSummaryLength = 10
+	defaultKeywordCount     = 5
+)
+
+// OptionsError is a mistake in a request's options, shown to the user.
+type OptionsError string
+
+func (e OptionsError) Error() string {
+	return string(e)
+}
+
+// Flags and their aliases, by option
+var formatFlags = map[string]Format{
+	"paragraph": Paragraph, "p": Paragraph,
+	"bullets": Bullets, "b": Bullets,
+	"keywords": KeyPoints, "keypoints": KeyPoints, "k": KeyPoints,
+}
+
+// parseOptions reads the options of a request, e.g. "@tldr 3 --bullets
+// <url>", on top of defaults: a number right after the mention, or after
+// "--length", is the summary's length in sentences, "--bullets",
+// "--paragraph" and "--keywords" set its format and "--lang=xx" its
+// language. Links, mentions and other words are ignored. Slack may turn
+// "--" into a dash, which is accepted too.
+func parseOptions(text string, defaults *Options, maxLength int) (*Options, error) {
+	opts := *defaults
+
+	// Fields that may be a length: the first of the text, as in slash
+	// commands, or right after a mention
+	var fields []string
+	leading := make(map[int]bool)
+	tokens := mrkdwn.Parse(text)
+	for i, t := range tokens {
+		if t.Kind != mrkdwn.TextToken {
+			continue
+		}
+		if i == 0 || tokens[i-1].Kind == mrkdwn.UserToken {
+			leading[len(fields)] = true
+		}
+		// History requests ("last 90 minutes") have numbers of their own
+		value := lastRe.ReplaceAllString(bareURLRe.ReplaceAllString(t.Value, " "), " ")
+		fields = append(fields, strings.Fields(value)...)
+	}
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if n, err := strconv.Atoi(field); err == nil {
+			if leading[i] {
+				opts.Length = n
+			}
+			continue
+		}
+
+		flag := strings.TrimLeft(field, "-—–")
+		if flag == field || flag == "" {
+			continue
+		}
+		name, value := strings.ToLower(flag), ""
+		if eq := strings.IndexByte(flag, '='); eq >= 0 {
+			name, value = strings.ToLower(flag[:eq]), flag[eq+1:]
+		}
+
+		if format, ok := formatFlags[name]; ok {
+			opts.Format = format
+			continue
+		}
+		switch name {
+		case "lang", "language", "length", "n":
+			if value == "" && i+1 < len(fields) {
+				i++
+				value = fields[i]
+			}
+			if value == "" {
+				return nil, OptionsError(fmt.Sprintf("%s needs a value.", mrkdwn.Code("--"+name)))
+			}
+			if name == "lang" || name == "language" {
+				opts.Language = strings.ToLower(value)
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, OptionsError(fmt.Sprintf("%s is not a number of sentences.", mrkdwn.Code(value)))
+			}
+			opts.Length = n
+		default:
+			return nil, OptionsError(fmt.Sprintf("I don't know the option %s. Try %s, %s, %s, %s or a number of sentences.",
+				mrkdwn.Code(field), mrkdwn.Code("--paragraph"), mrkdwn.Code("--bullets"), mrkdwn.Code("--keywords"), mrkdwn.Code("--lang=xx")))
+		}
+	}
+
+	if opts.Length < minSummaryLength || opts.Length > maxLength {
+		return nil, OptionsError(fmt.Sprintf("Summaries are %d to %d sentences long.", minSummaryLength, maxLength))
+	}
+	if opts.Format != KeyPoints {
+		opts.Keywords = 0
+	}
+	return &opts, nil
+}
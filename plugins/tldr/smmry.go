@@ -0,0 +1,192 @@
+package tldr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	smmryBaseURL   = "https://api.smmry.com/"
+	smmryAPIKeyEnv = "SMMRY_API_KEY"
+
+	// Marks the end of each sentence with SM_WITH_BREAK
+	smmryBreak = "[BREAK]"
+
+	// Requests left under which running out is worth a warning
+	smmryLowQuota = 10
+)
+
+var (
+	ErrSmmryInvalidKey      = errors.New("tldr: smmry: invalid API key")
+	ErrSmmryDailyLimit      = errors.New("tldr: smmry: daily request limit reached")
+	ErrSmmryUnsupportedPage = errors.New("tldr: smmry: page cannot be summarized")
+)
+
+// e.g. "Waited 0 extra seconds due to API limited mode, 89 requests left
+// to make for today."
+var smmryRemainingRe = regexp.MustCompile(`(\d+) requests? left`)
+
+// SmmryError is an error SMMRY reported that is none of the above. Codes
+// are 0 for a server problem, 1 for an invalid request, 2 for a
+// restricted API key and 3 for a summarization failure.
+type SmmryError struct {
+	Code    int
+	Message string
+}
+
+func (e *SmmryError) Error() string {
+	return fmt.Sprintf("tldr: smmry: error %d: %s", e.Code, e.Message)
+}
+
+// smmryError maps an error SMMRY reported to one of the errors above.
+func smmryError(code int, message string) error {
+	upper := strings.ToUpper(message)
+	switch {
+	case strings.Contains(upper, "LIMIT") || strings.Contains(upper, "CREDIT"):
+		return ErrSmmryDailyLimit
+	case strings.Contains(upper, "KEY"):
+		return ErrSmmryInvalidKey
+	case code == 3 || strings.Contains(upper, "TOO SHORT") || strings.Contains(upper, "SOURCE"):
+		return ErrSmmryUnsupportedPage
+	}
+	return &SmmryError{Code: code, Message: message}
+}
+
+// SmmrySummarizer uses the SMMRY API. The API key is read from the
+// SMMRY_API_KEY environment variable.
+type SmmrySummarizer struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+	log     *log.Logger
+}
+
+type smmryResult struct {
+	Title      string   `json:"sm_api_title"`
+	Content    string   `json:"sm_api_content"`
+	Keywords   []string `json:"sm_api_keyword_array"`
+	Limitation string   `json:"sm_api_limitation"`
+
+	// Set on errors only, so that 0 is told from missing
+	Error   *int   `json:"sm_api_error"`
+	Message string `json:"sm_api_message"`
+}
+
+func newSmmrySummarizer(cfg *ProviderCfg, logger *log.Logger) (Summarizer, error) {
+	apiKey := os.Getenv(smmryAPIKeyEnv)
+	if apiKey == "" {
+		return nil, errors.New("tldr: " + smmryAPIKeyEnv + " is not set")
+	}
+	return &SmmrySummarizer{
+		apiKey:  apiKey,
+		baseURL: smmryBaseURL,
+		client:  &http.Client{Timeout: cfg.timeout()},
+		log:     logger,
+	}, nil
+}
+
+func (s *SmmrySummarizer) params(opts *Options) url.Values {
+	params := url.Values{
+		"SM_API_KEY":    {s.apiKey},
+		"SM_LENGTH":     {strconv.Itoa(opts.Length)},
+		"SM_WITH_BREAK": {""},
+	}
+	if opts.Keywords > 0 {
+		params.Set("SM_KEYWORD_COUNT", strconv.Itoa(opts.Keywords))
+	}
+	return params
+}
+
+func (s *SmmrySummarizer) Summarize(ctx context.Context, pageURL string, opts *Options) (*Summary, error) {
+	params := s.params(opts)
+	params.Set("SM_URL", pageURL)
+	return s.do(ctx, "GET", params, nil)
+}
+
+func (s *SmmrySummarizer) SummarizeText(ctx context.Context, text string, opts *Options) (*Summary, error) {
+	form := url.Values{"sm_api_input": {text}}
+	return s.do(ctx, "POST", s.params(opts), form)
+}
+
+func (s *SmmrySummarizer) do(ctx context.Context, method string, params, form url.Values) (*Summary, error) {
+	req, err := http.NewRequest(method, s.baseURL+"?"+params.Encode(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result smmryResult
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		if resp.StatusCode/100 != 2 {
+			return nil, fmt.Errorf("tldr: smmry: %s", resp.Status)
+		}
+		return nil, err
+	}
+	s.updateQuota(result.Limitation)
+	if result.Error != nil {
+		s.log.Printf("SMMRY error (code: %d, message: %s)", *result.Error, result.Message)
+		return nil, smmryError(*result.Error, result.Message)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("tldr: smmry: %s", resp.Status)
+	}
+	if strings.TrimSpace(result.Content) == "" {
+		return nil, ErrNoSummary
+	}
+
+	summary := &Summary{Title: result.Title, Keywords: result.Keywords}
+	for _, sentence := range strings.Split(result.Content, smmryBreak) {
+		if sentence = strings.TrimSpace(sentence); sentence != "" {
+			summary.Sentences = append(summary.Sentences, sentence)
+		}
+	}
+	summary.Content = strings.Join(summary.Sentences, " ")
+	return summary, nil
+}
+
+// updateQuota publishes the requests left for today, as SMMRY reports
+// them in limitation, if it does: in the metrics and, when running low, in
+// the log.
+func (s *SmmrySummarizer) updateQuota(limitation string) {
+	match := smmryRemainingRe.FindStringSubmatch(limitation)
+	if match == nil {
+		return
+	}
+	remaining, err := strconv.Atoi(match[1])
+	if err != nil {
+		return
+	}
+
+	quota := new(expvar.Int)
+	quota.Set(int64(remaining))
+	metrics.Set("smmryremaining", quota)
+	if remaining <= smmryLowQuota {
+		s.log.Printf("SMMRY quota running low (remaining: %d)", remaining)
+	}
+}
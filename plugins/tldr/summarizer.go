@@ -0,0 +1,309 @@
+package tldr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dysfn/wasb/plugins/tldr/article"
+	"github.com/dysfn/wasb/wasb/mrkdwn"
+)
+
+const defaultProviderTimeout = 30 * time.Second
+
+var (
+	ErrNoSummary       = errors.New("tldr: provider returned no summary")
+	ErrTextUnsupported = errors.New("tldr: no summarizer for text")
+)
+
+// Format is how a summary is laid out.
+type Format string
+
+const (
+	Paragraph Format = "paragraph"
+	Bullets   Format = "bullets"
+
+	// Keywords followed by bullets
+	KeyPoints Format = "keywords"
+)
+
+var formats = map[Format]bool{Paragraph: true, Bullets: true, KeyPoints: true}
+
+// Options of a summary. Language is a hint for the providers that support
+// it.
+type Options struct {
+	Length   int
+	Format   Format
+	Keywords int
+	Language string
+}
+
+type Summary struct {
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	Sentences []string `json:"sentences,omitempty"`
+	Keywords  []string `json:"keywords,omitempty"`
+}
+
+func (s *Summary) sentences() []string {
+	if len(s.Sentences) > 0 {
+		return s.Sentences
+	}
+	return splitSentences([]string{s.Content})
+}
+
+// Mrkdwn lays out the summary in format.
+func (s *Summary) Mrkdwn(format Format) string {
+	if format == Paragraph || format == "" {
+		return mrkdwn.Escape(s.Content)
+	}
+
+	var lines []string
+	if format == KeyPoints && len(s.Keywords) > 0 {
+		lines = append(lines, "*Keywords:* "+mrkdwn.Escape(strings.Join(s.Keywords, ", ")))
+	}
+	for _, sentence := range s.sentences() {
+		lines = append(lines, "• "+mrkdwn.Escape(sentence))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Summarizer summarizes the page at url in about opts.Length sentences,
+// with opts.Keywords keywords if it can, giving up when ctx is done.
+type Summarizer interface {
+	Summarize(ctx context.Context, url string, opts *Options) (*Summary, error)
+}
+
+// TextSummarizer summarizes text, e.g. a conversation, rather than a page.
+type TextSummarizer interface {
+	SummarizeText(ctx context.Context, text string, opts *Options) (*Summary, error)
+}
+
+// ArticleSummarizer summarizes an article already fetched from url rather
+// than fetching it again.
+type ArticleSummarizer interface {
+	SummarizeArticle(ctx context.Context, url string, a *article.Article, opts *Options) (*Summary, error)
+}
+
+// summarizeArticle summarizes a with s if it can, has s summarize url
+// otherwise.
+func summarizeArticle(ctx context.Context, s Summarizer, url string, a *article.Article, opts *Options) (*Summary, error) {
+	if as, ok := s.(ArticleSummarizer); ok {
+		return as.SummarizeArticle(ctx, url, a, opts)
+	}
+	return s.Summarize(ctx, url, opts)
+}
+
+type ProviderCfg struct {
+	// "smmry", "http" or "extractive"
+	Provider string `json:"provider"`
+
+	// Endpoint of an HTTP summarizer
+	URL string `json:"url"`
+
+	// Seconds to wait for a summary
+	Timeout int `json:"timeout"`
+}
+
+func (cfg *ProviderCfg) timeout() time.Duration {
+	if cfg.Timeout <= 0 {
+		return defaultProviderTimeout
+	}
+	return time.Duration(cfg.Timeout) * time.Second
+}
+
+// Summarizer providers, by name
+var providers = map[string]func(cfg *ProviderCfg, logger *log.Logger) (Summarizer, error){
+	"smmry":      newSmmrySummarizer,
+	"http":       newHTTPSummarizer,
+	"extractive": newExtractiveSummarizer,
+}
+
+// defaultProvider is SMMRY if it has an API key, the offline extractive
+// summarizer otherwise.
+func defaultProvider() *ProviderCfg {
+	if os.Getenv(smmryAPIKeyEnv) != "" {
+		return &ProviderCfg{Provider: "smmry"}
+	}
+	return &ProviderCfg{Provider: "extractive"}
+}
+
+func newSummarizer(cfg *ProviderCfg, logger *log.Logger) (Summarizer, error) {
+	newProvider, ok := providers[cfg.Provider]
+	if !ok {
+		return nil, fmt.Errorf("tldr: unknown summarizer provider %q", cfg.Provider)
+	}
+	return newProvider(cfg, logger)
+}
+
+// HTTPSummarizer posts {"url": ..., "length": ..., "keywords": ...,
+// "language": ...}, with "text" instead of "url" for text, to a
+// self-hosted summarizer and expects {"title": ..., "content": ...,
+// "sentences": [...], "keywords": [...]} back (sentences and keywords are
+// optional), or {"error": ...} with a non-2xx status.
+type HTTPSummarizer struct {
+	URL    string
+	client *http.Client
+}
+
+func newHTTPSummarizer(cfg *ProviderCfg, logger *log.Logger) (Summarizer, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("tldr: http summarizer needs a url")
+	}
+	return &HTTPSummarizer{
+		URL:    cfg.URL,
+		client: &http.Client{Timeout: cfg.timeout()},
+	}, nil
+}
+
+type httpSummaryRequest struct {
+	URL      string `json:"url,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Length   int    `json:"length"`
+	Keywords int    `json:"keywords,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+type httpSummaryResponse struct {
+	Summary
+	Error string `json:"error"`
+}
+
+func (s *HTTPSummarizer) Summarize(ctx context.Context, url string, opts *Options) (*Summary, error) {
+	return s.post(ctx, &httpSummaryRequest{URL: url, Length: opts.Length, Keywords: opts.Keywords, Language: opts.Language})
+}
+
+func (s *HTTPSummarizer) SummarizeText(ctx context.Context, text string, opts *Options) (*Summary, error) {
+	return s.post(ctx, &httpSummaryRequest{Text: text, Length: opts.Length, Keywords: opts.Keywords, Language: opts.Language})
+}
+
+func (s *HTTPSummarizer) post(ctx context.Context, sreq *httpSummaryRequest) (*Summary, error) {
+	body, err := json.Marshal(sreq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result httpSummaryResponse
+	err = json.Unmarshal(body, &result)
+	if resp.StatusCode/100 != 2 {
+		if err == nil && result.Error != "" {
+			return nil, fmt.Errorf("tldr: %s: %s", s.URL, result.Error)
+		}
+		return nil, fmt.Errorf("tldr: %s: %s", s.URL, resp.Status)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(result.Content) == "" {
+		return nil, ErrNoSummary
+	}
+	return &result.Summary, nil
+}
+
+// FallbackError holds the errors of every summarizer of a Fallback, in
+// order.
+type FallbackError []error
+
+func (e FallbackError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// fallbackError returns the only error of errs as is, all of them as a
+// FallbackError otherwise.
+func fallbackError(errs []error, none error) error {
+	switch len(errs) {
+	case 0:
+		return none
+	case 1:
+		return errs[0]
+	}
+	return FallbackError(errs)
+}
+
+// Fallback tries its summarizers in order until one succeeds, e.g. when
+// the first errors or hits its quota, or ctx is done. Failures it recovers
+// from are logged to Log.
+type Fallback struct {
+	Summarizers []Summarizer
+	Log         *log.Logger
+}
+
+func (f *Fallback) Summarize(ctx context.Context, url string, opts *Options) (*Summary, error) {
+	return f.try(ctx, url, func(s Summarizer) (*Summary, error) {
+		return s.Summarize(ctx, url, opts)
+	})
+}
+
+// SummarizeArticle hands a to the summarizers that can take it.
+func (f *Fallback) SummarizeArticle(ctx context.Context, url string, a *article.Article, opts *Options) (*Summary, error) {
+	return f.try(ctx, url, func(s Summarizer) (*Summary, error) {
+		return summarizeArticle(ctx, s, url, a, opts)
+	})
+}
+
+func (f *Fallback) try(ctx context.Context, url string, summarize func(s Summarizer) (*Summary, error)) (*Summary, error) {
+	var errs []error
+	for i, s := range f.Summarizers {
+		summary, err := summarize(s)
+		if err == nil {
+			return summary, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, err)
+		if i+1 < len(f.Summarizers) {
+			f.Log.Printf("Summarizer %T failed, falling back (url: %s): %s", s, url, err)
+		}
+	}
+	return nil, fallbackError(errs, ErrNoSummary)
+}
+
+// SummarizeText tries the summarizers that can summarize text, in order.
+func (f *Fallback) SummarizeText(ctx context.Context, text string, opts *Options) (*Summary, error) {
+	var errs []error
+	for _, s := range f.Summarizers {
+		ts, ok := s.(TextSummarizer)
+		if !ok {
+			continue
+		}
+		summary, err := ts.SummarizeText(ctx, text, opts)
+		if err == nil {
+			return summary, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, err)
+		f.Log.Printf("Summarizer %T failed on text: %s", s, err)
+	}
+	return nil, fallbackError(errs, ErrTextUnsupported)
+}
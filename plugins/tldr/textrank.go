@@ -0,0 +1,312 @@
+package tldr
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	textRankDamping    = 0.85
+	textRankIterations = 50
+	textRankEpsilon    = 1e-4
+
+	// Sentences with fewer words are unlikely to carry the article's point
+	minSentenceWords = 4
+)
+
+// Words ending a sentence with a period that does not end the sentence
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true,
+	"jr": true, "st": true, "vs": true, "etc": true, "e.g": true, "i.e": true,
+	"inc": true, "ltd": true, "co": true, "corp": true, "no": true, "fig": true,
+	"jan": true, "feb": true, "mar": true, "apr": true, "jun": true, "jul": true,
+	"aug": true, "sep": true, "sept": true, "oct": true, "nov": true, "dec": true,
+}
+
+var stopWords = make(map[string]bool)
+
+func init() {
+	for _, w := range strings.Fields(`a about above after again against all am an and any are as at
+		be because been before being below between both but by can could did do does doing
+		down during each few for from further had has have having he her here hers herself
+		him himself his how i if in into is it its itself just me more most my myself no nor
+		not now of off on once only or other our ours ourselves out over own same she should
+		so some such than that the their theirs them themselves then there these they this
+		those through to too under until up very was we were what when where which while who
+		whom why will with would you your yours yourself yourselves also said says one two
+		new like may many much must us get got`) {
+		stopWords[w] = true
+	}
+}
+
+// splitSentences splits paragraphs into sentences at ".", "!" and "?"
+// followed by a space and a capital letter, digit or quote, except after
+// common abbreviations and initials.
+func splitSentences(paragraphs []string) []string {
+	var sentences []string
+	for _, p := range paragraphs {
+		p = strings.Join(strings.Fields(p), " ")
+		start := 0
+		for i := 0; i < len(p); i++ {
+			c := p[i]
+			if c != '.' && c != '!' && c != '?' {
+				continue
+			}
+			// Include closing quotes and brackets in the sentence
+			end := i + 1
+			for end < len(p) && strings.IndexByte(`"')]`, p[end]) >= 0 {
+				end++
+			}
+			if end+1 >= len(p) || p[end] != ' ' || !startsSentence(p[end+1:]) {
+				continue
+			}
+			if c == '.' && isAbbreviation(p[start:i]) {
+				continue
+			}
+			sentences = append(sentences, strings.TrimSpace(p[start:end]))
+			start = end + 1
+			i = end
+		}
+		if rest := strings.TrimSpace(p[start:]); rest != "" {
+			sentences = append(sentences, rest)
+		}
+	}
+	return sentences
+}
+
+func startsSentence(s string) bool {
+	for _, r := range s {
+		return unicode.IsUpper(r) || unicode.IsDigit(r) || strings.ContainsRune("\"'“‘(", r)
+	}
+	return false
+}
+
+// isAbbreviation reports whether text ends with an abbreviation or an
+// initial like the "J" of "J. R. R. Tolkien".
+func isAbbreviation(text string) bool {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return false
+	}
+	last := strings.TrimLeft(fields[len(fields)-1], `"'(`)
+	if len([]rune(last)) == 1 && unicode.IsUpper([]rune(last)[0]) {
+		return true
+	}
+	return abbreviations[strings.ToLower(last)]
+}
+
+// words returns the lower-cased content words of a sentence.
+func words(sentence string) []string {
+	ws := tokens(sentence)
+	for i, w := range ws {
+		ws[i] = stem(w)
+	}
+	return ws
+}
+
+// tokens returns the lowercase words of sentence, stop words excluded.
+func tokens(sentence string) []string {
+	var ws []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(sentence), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}) {
+		w = strings.Trim(w, "'")
+		if len(w) < 2 || stopWords[w] {
+			continue
+		}
+		ws = append(ws, w)
+	}
+	return ws
+}
+
+// stem strips plurals, crudely, so that they match their singular. Stems
+// are for scoring only, not for display.
+func stem(w string) string {
+	if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// tfidf returns the TF-IDF vector of each sentence, sentences being the
+// documents.
+func tfidf(sentences [][]string) []map[string]float64 {
+	df := make(map[string]int)
+	for _, s := range sentences {
+		seen := make(map[string]bool)
+		for _, w := range s {
+			if !seen[w] {
+				seen[w] = true
+				df[w]++
+			}
+		}
+	}
+
+	n := float64(len(sentences))
+	vectors := make([]map[string]float64, len(sentences))
+	for i, s := range sentences {
+		v := make(map[string]float64)
+		for _, w := range s {
+			v[w]++
+		}
+		for w, tf := range v {
+			v[w] = tf / float64(len(s)) * (1 + math.Log(n/float64(df[w])))
+		}
+		vectors[i] = v
+	}
+	return vectors
+}
+
+func cosine(a, b map[string]float64) float64 {
+	var dot, na, nb float64
+	for w, x := range a {
+		dot += x * b[w]
+		na += x * x
+	}
+	for _, y := range b {
+		nb += y * y
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// textRank scores sentences by running PageRank on the graph of their
+// TF-IDF similarities.
+func textRank(sentences [][]string) []float64 {
+	n := len(sentences)
+	vectors := tfidf(sentences)
+
+	weights := make([][]float64, n)
+	totals := make([]float64, n)
+	for i := range weights {
+		weights[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			sim := cosine(vectors[i], vectors[j])
+			weights[i][j], weights[j][i] = sim, sim
+			totals[i] += sim
+			totals[j] += sim
+		}
+	}
+
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = 1
+	}
+	for iter := 0; iter < textRankIterations; iter++ {
+		delta := 0.0
+		next := make([]float64, n)
+		for i := 0; i < n; i++ {
+			sum := 0.0
+			for j := 0; j < n; j++ {
+				if weights[j][i] > 0 && totals[j] > 0 {
+					sum += weights[j][i] / totals[j] * scores[j]
+				}
+			}
+			next[i] = 1 - textRankDamping + textRankDamping*sum
+			delta += math.Abs(next[i] - scores[i])
+		}
+		scores = next
+		if delta < textRankEpsilon {
+			break
+		}
+	}
+	return scores
+}
+
+// extract returns the length highest ranked sentences of text, in their
+// original order.
+func extract(paragraphs []string, length int) []string {
+	var sentences []string
+	var tokens [][]string
+	for _, s := range splitSentences(paragraphs) {
+		ws := words(s)
+		if len(strings.Fields(s)) < minSentenceWords || len(ws) == 0 {
+			continue
+		}
+		sentences = append(sentences, s)
+		tokens = append(tokens, ws)
+	}
+	if len(sentences) <= length {
+		return sentences
+	}
+
+	scores := textRank(tokens)
+	ranked := make([]int, len(sentences))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.Stable(byScore{ranked, scores})
+
+	top := ranked[:length]
+	sort.Ints(top)
+	summary := make([]string, len(top))
+	for i, idx := range top {
+		summary[i] = sentences[idx]
+	}
+	return summary
+}
+
+type byScore struct {
+	idx    []int
+	scores []float64
+}
+
+func (s byScore) Len() int           { return len(s.idx) }
+func (s byScore) Less(i, j int) bool { return s.scores[s.idx[i]] > s.scores[s.idx[j]] }
+func (s byScore) Swap(i, j int)      { s.idx[i], s.idx[j] = s.idx[j], s.idx[i] }
+
+// keywords returns the n most frequent content words of the paragraphs,
+// in order of frequency then of appearance.
+func keywords(paragraphs []string, n int) []string {
+	if n <= 0 {
+		return nil
+	}
+
+	// Words are counted by stem, and shown as their most frequent form
+	counts := make(map[string]int)
+	forms := make(map[string]map[string]int)
+	shown := make(map[string]string)
+	var order []string
+	for _, p := range paragraphs {
+		for _, w := range tokens(p) {
+			st := stem(w)
+			if len(st) < 3 {
+				continue
+			}
+			if counts[st] == 0 {
+				order = append(order, st)
+				forms[st] = make(map[string]int)
+			}
+			counts[st]++
+			forms[st][w]++
+			if shown[st] == "" || forms[st][w] > forms[st][shown[st]] {
+				shown[st] = w
+			}
+		}
+	}
+
+	sort.Stable(byCount{order, counts})
+	if len(order) > n {
+		order = order[:n]
+	}
+	keywords := make([]string, len(order))
+	for i, st := range order {
+		keywords[i] = shown[st]
+	}
+	return keywords
+}
+
+type byCount struct {
+	words  []string
+	counts map[string]int
+}
+
+func (c byCount) Len() int           { return len(c.words) }
+func (c byCount) Less(i, j int) bool { return c.counts[c.words[i]] > c.counts[c.words[j]] }
+func (c byCount) Swap(i, j int)      { c.words[i], c.words[j] = c.words[j], c.words[i] }
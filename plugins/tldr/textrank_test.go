@@ -0,0 +1,40 @@
+package tldr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeywords(t *testing.T) {
+	tests := []struct {
+		paragraphs []string
+		n          int
+		keywords   []string
+	}{
+		{
+			paragraphs: []string{
+				"The analysis of the business shows its status. The analysis process shows progress.",
+				"Business status and process analysis.",
+			},
+			n:        5,
+			keywords: []string{"analysis", "business", "shows", "status", "process"},
+		},
+		{
+			// Plurals count towards their singular, shown as written most
+			paragraphs: []string{"Reports, reports and one report.", "Servers crash."},
+			n:          2,
+			keywords:   []string{"reports", "servers"},
+		},
+		{
+			paragraphs: []string{"Cats and dogs."},
+			n:          0,
+		},
+	}
+
+	for _, test := range tests {
+		keywords := keywords(test.paragraphs, test.n)
+		if !reflect.DeepEqual(keywords, test.keywords) {
+			t.Errorf("%q: got keywords %q, want %q", test.paragraphs, keywords, test.keywords)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package tldr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dysfn/wasb/plugins/tldr/article"
+	"github.com/dysfn/wasb/wasb"
+	"github.com/dysfn/wasb/wasb/mrkdwn"
+)
+
+const (
+	defaultSummaryLength = 5
+	defaultTimeout       = 60
+	defaultProgressDelay = 5
+
+	progressReply = "Still working on it…"
+	timeoutReply  = "Sorry, that took too long. Try again later."
+	failedReply   = "Sorry, I couldn't summarize that."
+)
+
+func init() {
+	wasb.RegisterPlugin("tldr", func() wasb.Plugin { return &TLDR{} })
+}
+
+type Cfg struct {
+	// Number of sentences in a summary, and at most in one a user asks for
+	SummaryLength    int `json:"summarylength"`
+	MaxSummaryLength int `json:"maxsummarylength"`
+
+	// "paragraph", "bullets" or "keywords" (key points with keywords)
+	Format       string `json:"format"`
+	KeywordCount int    `json:"keywordcount"`
+
+	// Language hint for summarizers that support it
+	Language string `json:"language"`
+
+	// Summarizers to try in order. If empty, SMMRY is used when
+	// SMMRY_API_KEY is set and the offline extractive summarizer otherwise.
+	Summarizers []*ProviderCfg `json:"summarizers"`
+
+	// Summaries are cached by URL so that links posted several times are
+	// summarized once
+	Cache *CacheCfg `json:"cache"`
+
+	// Links summarized per message, and summarized at once: 5 and 3 if
+	// zero
+	MaxLinks    int `json:"maxlinks"`
+	Concurrency int `json:"concurrency"`
+
+	// Messages read at most to summarize a thread or channel, 500 by
+	// default
+	HistoryLimit int `json:"historylimit"`
+
+	// Links to these domains are summarized without a mention, if set
+	Unfurl *UnfurlCfg `json:"unfurl"`
+
+	// Seconds a request may take, and before saying it is still being
+	// worked on
+	Timeout       int `json:"timeout"`
+	ProgressDelay int `json:"progressdelay"`
+}
+
+type TLDR struct {
+	wasb.BasePlugin
+	log           *log.Logger
+	self          *wasb.RespRTMStartSelf
+	api           *wasb.API
+	replier       *wasb.Replier
+	users         *users
+	summarizer    Summarizer
+	cache         *Cache
+	defaults      *Options
+	maxLength     int
+	maxLinks      int
+	concurrency   int
+	historyLimit  int
+	unfurler      *unfurler
+	timeout       time.Duration
+	progressDelay time.Duration
+}
+
+func (bot *TLDR) Name() string {
+	return "tldr"
+}
+
+func (bot *TLDR) Init(raw json.RawMessage, svc *wasb.Services) error {
+	cfg := Cfg{
+		SummaryLength:    defaultSummaryLength,
+		MaxSummaryLength: defaultMaxSummaryLength,
+		Format:           string(Paragraph),
+		KeywordCount:     defaultKeywordCount,
+		MaxLinks:         defaultMaxLinks,
+		Concurrency:      defaultConcurrency,
+		HistoryLimit:     defaultHistoryLimit,
+		Timeout:          defaultTimeout,
+		ProgressDelay:    defaultProgressDelay,
+	}
+	if len(raw) > 0 {
+		err := json.Unmarshal(raw, &cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !formats[Format(cfg.Format)] {
+		return fmt.Errorf("tldr: unknown format %q", cfg.Format)
+	}
+	if cfg.SummaryLength < minSummaryLength || cfg.SummaryLength > cfg.MaxSummaryLength {
+		return fmt.Errorf("tldr: summary length %d out of range [%d, %d]", cfg.SummaryLength, minSummaryLength, cfg.MaxSummaryLength)
+	}
+	if cfg.MaxLinks == 0 {
+		cfg.MaxLinks = defaultMaxLinks
+	}
+	if cfg.Concurrency == 0 {
+		cfg.Concurrency = defaultConcurrency
+	}
+	if cfg.MaxLinks < 0 {
+		return fmt.Errorf("tldr: max links %d is negative", cfg.MaxLinks)
+	}
+	if cfg.Concurrency < 0 {
+		return fmt.Errorf("tldr: concurrency %d is negative", cfg.Concurrency)
+	}
+	if cfg.HistoryLimit <= 0 {
+		return fmt.Errorf("tldr: history limit %d is not positive", cfg.HistoryLimit)
+	}
+
+	if len(cfg.Summarizers) == 0 {
+		cfg.Summarizers = []*ProviderCfg{defaultProvider()}
+	}
+	summarizers := &Fallback{Log: svc.Log}
+	for _, providerCfg := range cfg.Summarizers {
+		summarizer, err := newSummarizer(providerCfg, svc.Log)
+		if err != nil {
+			return err
+		}
+		summarizers.Summarizers = append(summarizers.Summarizers, summarizer)
+	}
+
+	var summarizer Summarizer = summarizers
+	if len(summarizers.Summarizers) == 1 {
+		summarizer = summarizers.Summarizers[0]
+	}
+
+	bot.log = svc.Log
+	bot.self = svc.Self
+	bot.api = svc.API
+	bot.replier = svc.Replier
+	bot.users = &users{api: svc.API}
+	bot.cache = newCache(cfg.Cache, svc.Store, svc.Log)
+	bot.summarizer = &cachedSummarizer{
+		Summarizer: summarizer,
+		cache:      bot.cache,
+	}
+	bot.defaults = &Options{
+		Length:   cfg.SummaryLength,
+		Format:   Format(cfg.Format),
+		Keywords: cfg.KeywordCount,
+		Language: cfg.Language,
+	}
+	bot.maxLength = cfg.MaxSummaryLength
+	bot.maxLinks = cfg.MaxLinks
+	bot.concurrency = cfg.Concurrency
+	bot.historyLimit = cfg.HistoryLimit
+	bot.timeout = time.Duration(cfg.Timeout) * time.Second
+	bot.progressDelay = time.Duration(cfg.ProgressDelay) * time.Second
+	if cfg.Unfurl != nil && len(cfg.Unfurl.Domains) > 0 {
+		bot.unfurler = newUnfurler(cfg.Unfurl, svc.Store, svc.Log)
+	}
+	return nil
+}
+
+// Register adds the "@tldr <url>" and "@tldr this thread" message
+// handlers, automatic summaries of allowlisted links and the /tldr slash
+// command.
+func (bot *TLDR) Register(r *wasb.Router) {
+	r.HandleMessage(bot.isHistoryRequest, bot.summarizeHistory,
+		wasb.Named("tldr-history"),
+		wasb.Timeout(bot.timeout),
+		wasb.Progress(bot.progressDelay, progressReply),
+		wasb.Usage("@tldr this thread | @tldr last <n>[m|h|d] [in #channel]"),
+		wasb.Description("Summarize a thread or a channel's recent messages. Summaries of another channel are only shown to you."),
+		wasb.Example("@tldr this thread", "@tldr last 2h in #general"))
+	r.HandleMessage(bot.isSummaryRequest, bot.summarizeMessage,
+		wasb.Named("tldr"),
+		wasb.Timeout(bot.timeout),
+		wasb.Progress(bot.progressDelay, progressReply),
+		wasb.Usage("@tldr [<sentences>] [--bullets|--keywords] [--lang=xx] <url>..."),
+		wasb.Description("Summarize the web pages linked in a message"),
+		wasb.Example("@tldr https://blog.golang.org/context",
+			"@tldr 3 --bullets https://blog.golang.org/context",
+			"@tldr what about https://go.dev/blog/pipelines and https://go.dev/blog/race-detector?"))
+	r.HandleCommand("unfurl", bot.unfurlCommand,
+		wasb.Usage("@tldr unfurl [on|off]"),
+		wasb.Description("Turn automatic summaries of allowlisted links on or off in a channel"),
+		wasb.Example("@tldr unfurl on"))
+	r.HandleMessage(bot.isUnfurlCandidate, bot.unfurl,
+		wasb.Named("tldr-unfurl"),
+		wasb.Timeout(bot.timeout),
+		wasb.Hidden())
+	r.HandleSlash("/tldr", bot.Slash,
+		wasb.Timeout(bot.timeout),
+		wasb.Progress(bot.progressDelay, progressReply),
+		wasb.Usage("/tldr [<sentences>] [--bullets|--keywords] [--lang=xx] <url>..."),
+		wasb.Description("Summarize web pages"),
+		wasb.Example("/tldr https://blog.golang.org/context", "/tldr 3 --keywords https://blog.golang.org/context"))
+}
+
+// isSummaryRequest accepts messages mentioning the bot with at least one
+// link, e.g. "@tldr what about <a> and <b>?".
+func (bot *TLDR) isSummaryRequest(m *wasb.Msg) bool {
+	return m.Type == "message" && mentions(m.Text, bot.self.ID) && len(links(m.Text, 1)) > 0
+}
+
+func (bot *TLDR) summarize(ctx context.Context, url string, opts *Options) (*Summary, error) {
+	return bot.summarizer.Summarize(ctx, url, opts)
+}
+
+// timedOut reports whether err is a request running out of time.
+func timedOut(err error) bool {
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	t, ok := err.(interface {
+		Timeout() bool
+	})
+	return ok && t.Timeout()
+}
+
+// errorReply tells the user why a summary failed, rather than leaving them
+// with nothing.
+func errorReply(err error) string {
+	if timedOut(err) {
+		return timeoutReply
+	}
+	// The first summarizer's error the user can act on, e.g. SMMRY's
+	// quota, rather than the last one's
+	if errs, ok := err.(FallbackError); ok {
+		for _, err := range errs {
+			if reply := errorReply(err); reply != failedReply {
+				return reply
+			}
+		}
+		return failedReply
+	}
+	switch err {
+	case ErrSmmryDailyLimit:
+		return "I've used up today's summaries. Try again tomorrow."
+	case ErrSmmryInvalidKey:
+		return "My summarizer rejected its API key. Let an admin know."
+	case ErrSmmryUnsupportedPage, ErrNoSummary, article.ErrNoContent:
+		return "I couldn't find anything to summarize there."
+	case article.ErrNotHTML:
+		return "That doesn't look like a web page."
+	case article.ErrTooLarge:
+		return "That page is too large for me."
+	case article.ErrForbiddenAddress:
+		return "I don't fetch pages on private networks."
+	}
+	if statusErr, ok := err.(*article.StatusError); ok {
+		return fmt.Sprintf("I couldn't fetch the page (%d %s).", statusErr.Code, http.StatusText(statusErr.Code))
+	}
+	return failedReply
+}
+
+// card lays out a summary as a title, the summary itself and a link back to
+// the source. Summaries that do not fit in blocks are sent as text only.
+func card(url string, summary *Summary, format Format) wasb.Blocks {
+	var blocks wasb.Blocks
+	if summary.Title != "" {
+		blocks = append(blocks, wasb.Header(summary.Title))
+	}
+	blocks = append(blocks,
+		wasb.Section(wasb.Markdown(summary.Mrkdwn(format))),
+		wasb.Divider(),
+		wasb.Context(wasb.Markdown("Source: "+mrkdwn.Link(url, ""))),
+	)
+	if blocks.Validate() != nil {
+		return nil
+	}
+	return blocks
+}
+
+// render lays out the results as one response: a card for a single link,
+// a labelled section per link otherwise. It fails only if no link could be
+// summarized.
+func (bot *TLDR) render(results []*result, format Format) (string, wasb.Blocks, error) {
+	if len(results) == 1 {
+		r := results[0]
+		if r.err != nil {
+			return "", nil, r.err
+		}
+		return r.summary.Mrkdwn(format), card(r.link.URL, r.summary, format), nil
+	}
+
+	var texts []string
+	var blocks wasb.Blocks
+	var err error
+	summarized := false
+	for i, r := range results {
+		heading := "*" + mrkdwn.Link(r.link.URL, r.label()) + "*"
+		if i > 0 {
+			blocks = append(blocks, wasb.Divider())
+		}
+		if r.err != nil {
+			bot.log.Printf("Error summarizing %s: %s", r.link.URL, r.err)
+			if err == nil {
+				err = r.err
+			}
+			text := heading + "\n" + mrkdwn.Italic(errorReply(r.err))
+			texts = append(texts, text)
+			blocks = append(blocks, wasb.Context(wasb.Markdown(text)))
+			continue
+		}
+		summarized = true
+		text := heading + "\n" + r.summary.Mrkdwn(format)
+		texts = append(texts, text)
+		blocks = append(blocks, wasb.Section(wasb.Markdown(text)))
+	}
+	if !summarized {
+		return "", nil, err
+	}
+	if blocks.Validate() != nil {
+		blocks = nil
+	}
+	return strings.Join(texts, "\n\n"), blocks, nil
+}
+
+func (bot *TLDR) summarizeMessage(m *wasb.Msg) error {
+	opts, err := parseOptions(m.Text, bot.defaults, bot.maxLength)
+	if err != nil {
+		return bot.replier.ReplyText(m, err.Error())
+	}
+
+	ctx := m.Context()
+	text, blocks, err := bot.render(bot.summarizeAll(ctx, links(m.Text, bot.maxLinks), opts), opts.Format)
+	if err != nil {
+		bot.log.Printf("Error summarizing message (channel: %s, ts: %s): %s", m.Channel, m.TS, err)
+		return bot.replier.ReplyText(m, errorReply(err))
+	}
+
+	resp := &wasb.Msg{
+		Type:   "message",
+		Text:   text,
+		Blocks: blocks,
+	}
+	err = bot.replier.Reply(m, resp)
+	return err
+}
+
+func (bot *TLDR) Slash(c *wasb.SlashCommand) (*wasb.Response, error) {
+	ls := links(c.Text, bot.maxLinks)
+	if len(ls) == 0 {
+		return &wasb.Response{
+			ResponseType: wasb.ResponseEphemeral,
+			Text:         "Usage: " + c.Command + " https://...",
+		}, nil
+	}
+
+	opts, err := parseOptions(c.Text, bot.defaults, bot.maxLength)
+	if err != nil {
+		return &wasb.Response{
+			ResponseType: wasb.ResponseEphemeral,
+			Text:         err.Error(),
+		}, nil
+	}
+
+	ctx := c.Context()
+	text, blocks, err := bot.render(bot.summarizeAll(ctx, ls, opts), opts.Format)
+	if err != nil {
+		bot.log.Printf("Error summarizing (command: %s, user: %s): %s", c.Command, c.UserID, err)
+		return &wasb.Response{
+			ResponseType: wasb.ResponseEphemeral,
+			Text:         errorReply(err),
+		}, nil
+	}
+	return &wasb.Response{
+		ResponseType: wasb.ResponseInChannel,
+		Text:         text,
+		Blocks:       blocks,
+	}, nil
+}
@@ -0,0 +1,273 @@
+package tldr
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dysfn/wasb/plugins/tldr/article"
+	"github.com/dysfn/wasb/wasb"
+	"github.com/dysfn/wasb/wasb/mrkdwn"
+)
+
+const (
+	unfurlChannelsNamespace = "tldr.unfurl.channels"
+	unfurlSeenNamespace     = "tldr.unfurl.seen"
+
+	defaultUnfurlMinLength = 1500
+	defaultUnfurlWindow    = 24 * time.Hour
+)
+
+type UnfurlCfg struct {
+	// Domains whose links are summarized without a mention, subdomains
+	// included
+	Domains []string `json:"domains"`
+
+	// Channels opted in from the start. Others opt in with
+	// "@tldr unfurl on", and any channel out with "@tldr unfurl off".
+	Channels []string `json:"channels"`
+
+	// Characters of text an article needs to be worth a summary, 1500 if
+	// zero
+	MinLength int `json:"minlength"`
+
+	// Seconds during which a link is summarized once per channel, a day if
+	// zero
+	Window int `json:"window"`
+}
+
+// unfurler posts summaries of links to allowlisted domains in a thread,
+// in the channels that opted in.
+type unfurler struct {
+	domains   []string
+	channels  map[string]bool
+	minLength int
+	window    time.Duration
+	fetcher   *article.Fetcher
+	store     wasb.Store
+	log       *log.Logger
+
+	// Links being summarized, by key. Serializes checking and marking
+	// links as seen.
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+func newUnfurler(cfg *UnfurlCfg, store wasb.Store, logger *log.Logger) *unfurler {
+	u := &unfurler{
+		channels:  make(map[string]bool),
+		pending:   make(map[string]bool),
+		minLength: defaultUnfurlMinLength,
+		window:    defaultUnfurlWindow,
+		fetcher:   article.NewFetcher(defaultProviderTimeout),
+		store:     store,
+		log:       logger,
+	}
+	for _, domain := range cfg.Domains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			u.domains = append(u.domains, domain)
+		}
+	}
+	for _, channel := range cfg.Channels {
+		u.channels[channel] = true
+	}
+	if cfg.MinLength > 0 {
+		u.minLength = cfg.MinLength
+	}
+	if cfg.Window > 0 {
+		u.window = time.Duration(cfg.Window) * time.Second
+	}
+	return u
+}
+
+// allowed reports whether rawurl is on one of the allowlisted domains.
+func (u *unfurler) allowed(rawurl string) bool {
+	parsed, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+	host, _ := splitPort(strings.ToLower(parsed.Host))
+	for _, domain := range u.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether channel opted in, the store overriding the
+// configuration.
+func (u *unfurler) enabled(channel string) bool {
+	value, err := u.store.Get(unfurlChannelsNamespace, channel)
+	if err != nil {
+		return u.channels[channel]
+	}
+	return string(value) == "on"
+}
+
+func (u *unfurler) enable(channel string, on bool) error {
+	value := "off"
+	if on {
+		value = "on"
+	}
+	return u.store.Set(unfurlChannelsNamespace, channel, []byte(value), 0)
+}
+
+// links returns the allowlisted links of text.
+func (u *unfurler) links(text string, max int) []*link {
+	var ls []*link
+	for _, l := range links(text, max) {
+		if u.allowed(l.URL) {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+func seenKey(channel, rawurl string) string {
+	return channel + " " + normalizeURL(rawurl)
+}
+
+// claim reports whether the link was neither summarized in channel within
+// the window nor is being summarized, and marks it as being summarized
+// until released.
+func (u *unfurler) claim(channel, rawurl string) bool {
+	key := seenKey(channel, rawurl)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.pending[key] {
+		return false
+	}
+	_, err := u.store.Get(unfurlSeenNamespace, key)
+	if err == nil {
+		return false
+	}
+	u.pending[key] = true
+	return true
+}
+
+// release ends the summary of a claimed link, marking it as seen for the
+// window if it was posted.
+func (u *unfurler) release(channel, rawurl string, posted bool) {
+	key := seenKey(channel, rawurl)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.pending, key)
+	if !posted {
+		return
+	}
+	err := u.store.Set(unfurlSeenNamespace, key, []byte("1"), u.window)
+	if err != nil {
+		u.log.Printf("Error marking link as seen (key: %s): %s", key, err)
+	}
+}
+
+// summarizeLong summarizes the linked article if it is long enough to need
+// it, nil otherwise. Summaries already made are not fetched again.
+func (bot *TLDR) summarizeLong(ctx context.Context, l *link) (*Summary, error) {
+	if summary, ok := bot.cache.Get(l.URL, bot.defaults); ok {
+		return summary, nil
+	}
+	a, err := bot.unfurler.fetcher.Fetch(ctx, l.URL)
+	if err != nil {
+		return nil, err
+	}
+	if a.Length() < bot.unfurler.minLength {
+		return nil, nil
+	}
+	return summarizeArticle(ctx, bot.summarizer, l.URL, a, bot.defaults)
+}
+
+// isUnfurlCandidate accepts messages of opted-in channels with links to
+// allowlisted domains. Messages mentioning the bot are requests of their
+// own.
+func (bot *TLDR) isUnfurlCandidate(m *wasb.Msg) bool {
+	if bot.unfurler == nil || m.Type != "message" || m.Subtype != "" || mentions(m.Text, bot.self.ID) {
+		return false
+	}
+	return len(bot.unfurler.links(m.Text, bot.maxLinks)) > 0 && bot.unfurler.enabled(m.Channel)
+}
+
+// unfurl summarizes in a thread the links of m not summarized in its
+// channel lately and long enough to need it. Failures are only logged:
+// nobody asked for these summaries.
+func (bot *TLDR) unfurl(m *wasb.Msg) (err error) {
+	var ls []*link
+	for _, l := range bot.unfurler.links(m.Text, bot.maxLinks) {
+		if bot.unfurler.claim(m.Channel, l.URL) {
+			ls = append(ls, l)
+		}
+	}
+	if len(ls) == 0 {
+		return nil
+	}
+
+	// Only links posted are seen: others are tried again when shared again
+	var results []*result
+	defer func() {
+		posted := make(map[*link]bool)
+		if err == nil {
+			for _, r := range results {
+				posted[r.link] = true
+			}
+		}
+		for _, l := range ls {
+			bot.unfurler.release(m.Channel, l.URL, posted[l])
+		}
+	}()
+
+	for _, r := range bot.eachLink(m.Context(), ls, bot.summarizeLong) {
+		switch {
+		case r.err != nil:
+			bot.log.Printf("Error summarizing link (url: %s): %s", r.link.URL, r.err)
+		case r.summary != nil:
+			results = append(results, r)
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	text, blocks, err := bot.render(results, bot.defaults.Format)
+	if err != nil {
+		return err
+	}
+	return bot.replier.ReplyInThread(m, &wasb.Msg{Type: "message", Text: text, Blocks: blocks}, false)
+}
+
+// unfurlCommand handles "@tldr unfurl on|off".
+func (bot *TLDR) unfurlCommand(m *wasb.Msg, args string) error {
+	if bot.unfurler == nil {
+		return bot.replier.ReplyText(m, "Automatic summaries are not configured.")
+	}
+
+	var on bool
+	switch strings.ToLower(args) {
+	case "on":
+		on = true
+	case "off":
+	case "":
+		state := "off"
+		if bot.unfurler.enabled(m.Channel) {
+			state = "on"
+		}
+		return bot.replier.ReplyText(m, "Automatic summaries are "+state+" in "+mrkdwn.Channel(m.Channel)+".")
+	default:
+		return bot.replier.ReplyText(m, "Usage: @tldr unfurl on|off")
+	}
+
+	err := bot.unfurler.enable(m.Channel, on)
+	if err != nil {
+		return err
+	}
+	if on {
+		return bot.replier.ReplyText(m, "I'll summarize links to "+strings.Join(bot.unfurler.domains, ", ")+" posted in "+mrkdwn.Channel(m.Channel)+".")
+	}
+	return bot.replier.ReplyText(m, "I'll stop summarizing links in "+mrkdwn.Channel(m.Channel)+" unless asked.")
+}
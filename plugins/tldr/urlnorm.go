@@ -0,0 +1,62 @@
+package tldr
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Query parameters that only track where a click came from
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true,
+	"igshid": true, "mc_cid": true, "mc_eid": true, "_ga": true, "_hsenc": true,
+	"_hsmi": true, "mkt_tok": true, "ref_src": true, "ref_url": true, "cmpid": true,
+	"spm": true, "share": true, "s_cid": true, "ocid": true,
+}
+
+// normalizeURL canonicalizes rawurl so that links to the same page share
+// a key: the scheme is dropped (http and https are taken to serve the same
+// page), the host lower-cased without "www." and default ports, tracking
+// parameters and the fragment removed and the remaining parameters
+// sorted. URLs that do not parse are returned as is.
+func normalizeURL(rawurl string) string {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil || u.Host == "" {
+		return rawurl
+	}
+
+	host := strings.ToLower(u.Host)
+	if h, port := splitPort(host); port == "80" || port == "443" {
+		host = h
+	}
+	host = strings.TrimPrefix(host, "www.")
+
+	path := u.EscapedPath()
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	if path == "/" {
+		path = ""
+	}
+
+	query := u.Query()
+	for name := range query {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "utm_") || trackingParams[lower] {
+			query.Del(name)
+		}
+	}
+
+	key := host + path
+	if len(query) > 0 {
+		key += "?" + query.Encode()
+	}
+	return key
+}
+
+func splitPort(host string) (string, string) {
+	i := strings.LastIndexByte(host, ':')
+	if i < 0 || strings.IndexByte(host[i:], ']') >= 0 {
+		return host, ""
+	}
+	return host[:i], host[i+1:]
+}
@@ -0,0 +1,203 @@
+package wasb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	aclGroupTTL          = 5 * time.Minute
+	defaultDenialMessage = "Sorry, you are not allowed to do that here."
+)
+
+// ACLCfg restricts who can use the bot and where. Deny lists win over allow
+// lists, empty allow lists allow everyone. Roles map a name to Slack user
+// IDs (U...) and user group IDs (S...), Commands map a command to the role
+// required to run it. Buttons and menus are commands named after their
+// action ID, modals after their callback ID.
+type ACLCfg struct {
+	AllowChannels []string            `json:"allowchannels"`
+	DenyChannels  []string            `json:"denychannels"`
+	AllowUsers    []string            `json:"allowusers"`
+	DenyUsers     []string            `json:"denyusers"`
+	Roles         map[string][]string `json:"roles"`
+	Commands      map[string]string   `json:"commands"`
+	DenialMessage string              `json:"denialmessage"`
+}
+
+type DeniedError struct {
+	User    string
+	Channel string
+	Command string
+	Reason  string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("access denied: %s", e.Reason)
+}
+
+type userGroup struct {
+	users   map[string]bool
+	fetched time.Time
+
+	// Closed when the fetch in progress, if any, is done
+	fetching chan struct{}
+}
+
+type ACL struct {
+	cfg    *ACLCfg
+	api    *API
+	mu     sync.Mutex
+	groups map[string]*userGroup
+}
+
+// NewACL returns an ACL for cfg. api is used to look up user group members
+// and may be nil if roles only list users.
+func NewACL(cfg *ACLCfg, api *API) *ACL {
+	if cfg == nil {
+		cfg = &ACLCfg{}
+	}
+	return &ACL{
+		cfg:    cfg,
+		api:    api,
+		groups: make(map[string]*userGroup),
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a *DeniedError if user may not run command in channel. An
+// empty command only checks the channel and user lists. User groups are
+// looked up within ctx.
+func (acl *ACL) Check(ctx context.Context, user, channel, command string) error {
+	return acl.check(ctx, user, channel, command, true)
+}
+
+// check is Check, leaving denials out of the audit log unless audit is
+// set.
+func (acl *ACL) check(ctx context.Context, user, channel, command string, audit bool) error {
+	deny := func(reason string) error {
+		err := &DeniedError{User: user, Channel: channel, Command: command, Reason: reason}
+		if audit {
+			log.Printf("audit: denied user=%s channel=%s command=%q reason=%q", user, channel, command, reason)
+		}
+		return err
+	}
+
+	if contains(acl.cfg.DenyChannels, channel) {
+		return deny("channel is denied")
+	}
+	if len(acl.cfg.AllowChannels) > 0 && !contains(acl.cfg.AllowChannels, channel) {
+		return deny("channel is not allowed")
+	}
+	if contains(acl.cfg.DenyUsers, user) {
+		return deny("user is denied")
+	}
+	if len(acl.cfg.AllowUsers) > 0 && !contains(acl.cfg.AllowUsers, user) {
+		return deny("user is not allowed")
+	}
+
+	role := acl.RequiredRole(command)
+	if role != "" && !acl.HasRole(ctx, user, role) {
+		return deny(fmt.Sprintf("role %s required", role))
+	}
+	return nil
+}
+
+func (acl *ACL) RequiredRole(command string) string {
+	if command == "" {
+		return ""
+	}
+	return acl.cfg.Commands[command]
+}
+
+func (acl *ACL) HasRole(ctx context.Context, user, role string) bool {
+	for _, member := range acl.cfg.Roles[role] {
+		if member == user {
+			return true
+		}
+		if strings.HasPrefix(member, "S") && acl.inGroup(ctx, user, member) {
+			return true
+		}
+	}
+	return false
+}
+
+// inGroup looks up the members of group once per aclGroupTTL, outside the
+// lock: checks of the same group wait for the fetch in progress, each until
+// its own ctx is done.
+func (acl *ACL) inGroup(ctx context.Context, user, group string) bool {
+	if acl.api == nil {
+		return false
+	}
+
+	acl.mu.Lock()
+	g, ok := acl.groups[group]
+	if !ok {
+		g = &userGroup{}
+		acl.groups[group] = g
+	}
+	if g.users != nil && time.Since(g.fetched) <= aclGroupTTL {
+		member := g.users[user]
+		acl.mu.Unlock()
+		return member
+	}
+	fetching := g.fetching
+	if fetching == nil {
+		fetching = make(chan struct{})
+		g.fetching = fetching
+		go acl.fetchGroup(group, g, fetching)
+	}
+	acl.mu.Unlock()
+
+	select {
+	case <-fetching:
+	case <-ctx.Done():
+	}
+
+	// Stale members, if the fetch failed, rather than locking everyone out
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+	return g.users[user]
+}
+
+// fetchGroup outlives the check that started it, for the others waiting.
+func (acl *ACL) fetchGroup(group string, g *userGroup, done chan struct{}) {
+	defer close(done)
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+	users, err := acl.api.WithContext(ctx).UserGroupMembers(group)
+	if err != nil {
+		log.Printf("Error fetching members of user group %s: %s", group, err)
+	}
+
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+	g.fetching = nil
+	if err != nil {
+		return
+	}
+	g.users = make(map[string]bool)
+	for _, u := range users {
+		g.users[u] = true
+	}
+	g.fetched = time.Now()
+}
+
+func (acl *ACL) DenialMessage() string {
+	if acl.cfg.DenialMessage != "" {
+		return acl.cfg.DenialMessage
+	}
+	return defaultDenialMessage
+}
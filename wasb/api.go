@@ -0,0 +1,374 @@
+package wasb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	slackURLAPI     = "https://slack.com/api/"
+	apiTimeout      = 30 * time.Second
+	apiMaxRetries   = 3
+	apiDefaultRetry = time.Second
+)
+
+type APIError struct {
+	Method string
+	Code   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Method, e.Code)
+}
+
+// API is a minimal client for Slack's Web API.
+type API struct {
+	token   string
+	baseURL string
+	client  *http.Client
+	ctx     context.Context
+}
+
+type respAPI struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+type RespPostMessage struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+type reqPostMessage struct {
+	Channel        string `json:"channel"`
+	Text           string `json:"text,omitempty"`
+	Blocks         Blocks `json:"blocks,omitempty"`
+	ThreadTS       string `json:"thread_ts,omitempty"`
+	ReplyBroadcast bool   `json:"reply_broadcast,omitempty"`
+}
+
+func NewAPI(token string) *API {
+	return &API{
+		token:   token,
+		baseURL: slackURLAPI,
+		client:  &http.Client{Timeout: apiTimeout},
+		ctx:     context.Background(),
+	}
+}
+
+// WithContext returns a client whose calls give up when ctx is done, e.g.
+// one bound to a message's handling.
+func (api *API) WithContext(ctx context.Context) *API {
+	api2 := *api
+	api2.ctx = ctx
+	return &api2
+}
+
+// Call invokes a Web API method with a JSON body and decodes the response
+// into result (if not nil). Rate limited calls are retried after the delay
+// Slack asks for.
+func (api *API) Call(method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return api.do(method, "application/json; charset=utf-8", body, result)
+}
+
+// CallForm is like Call for the methods that only accept form encoded
+// arguments.
+func (api *API) CallForm(method string, params url.Values, result interface{}) error {
+	return api.do(method, "application/x-www-form-urlencoded", []byte(params.Encode()), result)
+}
+
+func (api *API) do(method, contentType string, body []byte, result interface{}) error {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("POST", api.baseURL+method, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(api.ctx)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+api.token)
+
+		resp, err := api.client.Do(req)
+		if err != nil {
+			return err
+		}
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < apiMaxRetries {
+			delay := apiDefaultRetry
+			secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+			if err == nil {
+				delay = time.Duration(secs) * time.Second
+			}
+			select {
+			case <-time.After(delay):
+			case <-api.ctx.Done():
+				return api.ctx.Err()
+			}
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return &APIError{Method: method, Code: resp.Status}
+		}
+
+		var status respAPI
+		err = json.Unmarshal(respBody, &status)
+		if err != nil {
+			return err
+		}
+		if !status.OK {
+			return &APIError{Method: method, Code: status.Error}
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(respBody, result)
+	}
+}
+
+func (api *API) PostMessage(m *Msg) (*RespPostMessage, error) {
+	if len(m.Blocks) > 0 {
+		err := m.Blocks.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req := &reqPostMessage{
+		Channel:        m.Channel,
+		Text:           m.Text,
+		Blocks:         m.Blocks,
+		ThreadTS:       m.ThreadTS,
+		ReplyBroadcast: m.ReplyBroadcast,
+	}
+	var result RespPostMessage
+	err := api.Call("chat.postMessage", req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+type reqPostEphemeral struct {
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	Text     string `json:"text,omitempty"`
+	Blocks   Blocks `json:"blocks,omitempty"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+}
+
+// PostEphemeral shows m in its channel to user only.
+func (api *API) PostEphemeral(m *Msg, user string) error {
+	if len(m.Blocks) > 0 {
+		err := m.Blocks.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	req := &reqPostEphemeral{
+		Channel:  m.Channel,
+		User:     user,
+		Text:     m.Text,
+		Blocks:   m.Blocks,
+		ThreadTS: m.ThreadTS,
+	}
+	return api.Call("chat.postEphemeral", req, nil)
+}
+
+type respUploadURL struct {
+	UploadURL string `json:"upload_url"`
+	FileID    string `json:"file_id"`
+}
+
+// UploadSnippet shares content as a text file in a channel (and thread, if
+// threadTS is set).
+func (api *API) UploadSnippet(channel, threadTS, filename, content string) error {
+	var upload respUploadURL
+	err := api.CallForm("files.getUploadURLExternal", url.Values{
+		"filename": {filename},
+		"length":   {strconv.Itoa(len(content))},
+	}, &upload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", upload.UploadURL, strings.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	resp, err := api.client.Do(req.WithContext(api.ctx))
+	if err != nil {
+		return err
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{Method: "upload", Code: resp.Status}
+	}
+
+	files, err := json.Marshal([]map[string]string{{"id": upload.FileID, "title": filename}})
+	if err != nil {
+		return err
+	}
+	params := url.Values{
+		"files":      {string(files)},
+		"channel_id": {channel},
+	}
+	if threadTS != "" {
+		params.Set("thread_ts", threadTS)
+	}
+	return api.CallForm("files.completeUploadExternal", params, nil)
+}
+
+type respUserGroupMembers struct {
+	Users []string `json:"users"`
+}
+
+func (api *API) UserGroupMembers(group string) ([]string, error) {
+	var result respUserGroupMembers
+	err := api.CallForm("usergroups.users.list", url.Values{"usergroup": {group}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Users, nil
+}
+
+type reqAddReaction struct {
+	Channel   string `json:"channel"`
+	Timestamp string `json:"timestamp"`
+	Name      string `json:"name"`
+}
+
+func (api *API) AddReaction(channel, ts, name string) error {
+	return api.Call("reactions.add", &reqAddReaction{Channel: channel, Timestamp: ts, Name: name}, nil)
+}
+
+// Messages fetched per page of conversation history
+const historyPageSize = 200
+
+type respHistory struct {
+	Messages         []*Msg `json:"messages"`
+	HasMore          bool   `json:"has_more"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// history pages through a conversations method until limit messages are
+// fetched or there are no more. A limit of 0 or less fetches them all.
+func (api *API) history(method string, params url.Values, limit int) ([]*Msg, error) {
+	var msgs []*Msg
+	params.Set("limit", strconv.Itoa(historyPageSize))
+	for {
+		var result respHistory
+		err := api.CallForm(method, params, &result)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, result.Messages...)
+		if limit > 0 && len(msgs) >= limit {
+			return msgs[:limit], nil
+		}
+		cursor := result.ResponseMetadata.NextCursor
+		if !result.HasMore || cursor == "" {
+			return msgs, nil
+		}
+		params.Set("cursor", cursor)
+	}
+}
+
+// ConversationHistory returns up to limit messages posted in channel since
+// oldest (a message ts, or "" for the beginning), newest first. Thread
+// replies are not included. A limit of 0 or less means no limit.
+func (api *API) ConversationHistory(channel, oldest string, limit int) ([]*Msg, error) {
+	params := url.Values{"channel": {channel}}
+	if oldest != "" {
+		params.Set("oldest", oldest)
+	}
+	return api.history("conversations.history", params, limit)
+}
+
+// ConversationReplies returns up to limit messages of the thread started
+// by ts, the parent message first, without limit if it is 0 or less.
+func (api *API) ConversationReplies(channel, ts string, limit int) ([]*Msg, error) {
+	return api.history("conversations.replies", url.Values{"channel": {channel}, "ts": {ts}}, limit)
+}
+
+type respMembers struct {
+	Members          []string `json:"members"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// ConversationMembers returns the IDs of the members of channel.
+func (api *API) ConversationMembers(channel string) ([]string, error) {
+	var members []string
+	params := url.Values{"channel": {channel}, "limit": {"1000"}}
+	for {
+		var result respMembers
+		err := api.CallForm("conversations.members", params, &result)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, result.Members...)
+		if result.ResponseMetadata.NextCursor == "" {
+			return members, nil
+		}
+		params.Set("cursor", result.ResponseMetadata.NextCursor)
+	}
+}
+
+type User struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	RealName string `json:"real_name"`
+	IsBot    bool   `json:"is_bot"`
+	Profile  struct {
+		DisplayName string `json:"display_name"`
+		RealName    string `json:"real_name"`
+	} `json:"profile"`
+}
+
+// DisplayName returns the name Slack shows for the user.
+func (u *User) DisplayName() string {
+	switch {
+	case u.Profile.DisplayName != "":
+		return u.Profile.DisplayName
+	case u.Profile.RealName != "":
+		return u.Profile.RealName
+	case u.RealName != "":
+		return u.RealName
+	}
+	return u.Name
+}
+
+type respUserInfo struct {
+	User *User `json:"user"`
+}
+
+func (api *API) UserInfo(user string) (*User, error) {
+	var result respUserInfo
+	err := api.CallForm("users.info", url.Values{"user": {user}}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.User, nil
+}
@@ -0,0 +1,504 @@
+package wasb
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	ButtonStylePrimary = "primary"
+	ButtonStyleDanger  = "danger"
+)
+
+const (
+	textTypePlain       = "plain_text"
+	textTypeMarkdown    = "mrkdwn"
+	blockTypeSection    = "section"
+	blockTypeHeader     = "header"
+	blockTypeDivider    = "divider"
+	blockTypeContext    = "context"
+	blockTypeImage      = "image"
+	blockTypeActions    = "actions"
+	elementTypeButton   = "button"
+	elementTypeImage    = "image"
+	elementTypeOverflow = "overflow"
+)
+
+// Size limits documented at https://api.slack.com/reference/block-kit
+const (
+	maxBlocksPerMessage  = 50
+	maxBlockIDLength     = 255
+	maxActionIDLength    = 255
+	maxHeaderTextLength  = 150
+	maxSectionTextLength = 3000
+	maxSectionFields     = 10
+	maxFieldTextLength   = 2000
+	maxContextElements   = 10
+	maxActionsElements   = 25
+	maxImageURLLength    = 3000
+	maxAltTextLength     = 2000
+	maxButtonTextLength  = 75
+	maxButtonValueLength = 2000
+	maxButtonURLLength   = 3000
+	maxOptionTextLength  = 75
+	maxOptionValueLength = 150
+	minOverflowOptions   = 2
+	maxOverflowOptions   = 5
+)
+
+type Block interface {
+	Validate() error
+}
+
+// Element is anything that can go in an actions block or a section's
+// accessory.
+type Element interface {
+	Validate() error
+}
+
+// ContextElement is a text object or an image element.
+type ContextElement interface {
+	Validate() error
+}
+
+type Blocks []Block
+
+func (bs Blocks) Validate() error {
+	if len(bs) > maxBlocksPerMessage {
+		return fmt.Errorf("blocks: %d blocks exceed the limit of %d", len(bs), maxBlocksPerMessage)
+	}
+	for i, b := range bs {
+		err := b.Validate()
+		if err != nil {
+			return fmt.Errorf("blocks[%d]: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON keeps blocks of received messages as raw JSON since only
+// outbound blocks are built with the typed API.
+func (bs *Blocks) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*bs = make(Blocks, len(raw))
+	for i, r := range raw {
+		(*bs)[i] = RawBlock(r)
+	}
+	return nil
+}
+
+type RawBlock json.RawMessage
+
+func (b RawBlock) Validate() error {
+	return nil
+}
+
+func (b RawBlock) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(b).MarshalJSON()
+}
+
+func checkLength(what, s string, max int) error {
+	if utf8.RuneCountInString(s) > max {
+		return fmt.Errorf("%s exceeds %d characters", what, max)
+	}
+	return nil
+}
+
+type TextObject struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Emoji    bool   `json:"emoji,omitempty"`
+	Verbatim bool   `json:"verbatim,omitempty"`
+}
+
+func PlainText(text string) *TextObject {
+	return &TextObject{Type: textTypePlain, Text: text, Emoji: true}
+}
+
+func Markdown(text string) *TextObject {
+	return &TextObject{Type: textTypeMarkdown, Text: text}
+}
+
+func (t *TextObject) Validate() error {
+	if t.Type != textTypePlain && t.Type != textTypeMarkdown {
+		return fmt.Errorf("text: unknown type %q", t.Type)
+	}
+	if t.Text == "" {
+		return fmt.Errorf("text: empty")
+	}
+	return nil
+}
+
+type SectionBlock struct {
+	BlockID   string        `json:"block_id,omitempty"`
+	Text      *TextObject   `json:"text,omitempty"`
+	Fields    []*TextObject `json:"fields,omitempty"`
+	Accessory Element       `json:"accessory,omitempty"`
+}
+
+func Section(text *TextObject) *SectionBlock {
+	return &SectionBlock{Text: text}
+}
+
+func (b *SectionBlock) WithFields(fields ...*TextObject) *SectionBlock {
+	b.Fields = append(b.Fields, fields...)
+	return b
+}
+
+func (b *SectionBlock) WithAccessory(e Element) *SectionBlock {
+	b.Accessory = e
+	return b
+}
+
+func (b *SectionBlock) WithID(blockID string) *SectionBlock {
+	b.BlockID = blockID
+	return b
+}
+
+func (b *SectionBlock) Validate() error {
+	if b.Text == nil && len(b.Fields) == 0 {
+		return fmt.Errorf("section: needs text or fields")
+	}
+	err := checkLength("section block_id", b.BlockID, maxBlockIDLength)
+	if err != nil {
+		return err
+	}
+	if b.Text != nil {
+		err = b.Text.Validate()
+		if err != nil {
+			return err
+		}
+		err = checkLength("section text", b.Text.Text, maxSectionTextLength)
+		if err != nil {
+			return err
+		}
+	}
+	if len(b.Fields) > maxSectionFields {
+		return fmt.Errorf("section: %d fields exceed the limit of %d", len(b.Fields), maxSectionFields)
+	}
+	for _, f := range b.Fields {
+		err = f.Validate()
+		if err != nil {
+			return err
+		}
+		err = checkLength("section field", f.Text, maxFieldTextLength)
+		if err != nil {
+			return err
+		}
+	}
+	if b.Accessory != nil {
+		return b.Accessory.Validate()
+	}
+	return nil
+}
+
+func (b *SectionBlock) MarshalJSON() ([]byte, error) {
+	type alias SectionBlock
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*alias
+	}{blockTypeSection, (*alias)(b)})
+}
+
+type HeaderBlock struct {
+	BlockID string      `json:"block_id,omitempty"`
+	Text    *TextObject `json:"text"`
+}
+
+func Header(text string) *HeaderBlock {
+	return &HeaderBlock{Text: PlainText(text)}
+}
+
+func (b *HeaderBlock) Validate() error {
+	if b.Text == nil || b.Text.Type != textTypePlain {
+		return fmt.Errorf("header: needs plain text")
+	}
+	err := checkLength("header block_id", b.BlockID, maxBlockIDLength)
+	if err != nil {
+		return err
+	}
+	return checkLength("header text", b.Text.Text, maxHeaderTextLength)
+}
+
+func (b *HeaderBlock) MarshalJSON() ([]byte, error) {
+	type alias HeaderBlock
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*alias
+	}{blockTypeHeader, (*alias)(b)})
+}
+
+type DividerBlock struct {
+	BlockID string `json:"block_id,omitempty"`
+}
+
+func Divider() *DividerBlock {
+	return &DividerBlock{}
+}
+
+func (b *DividerBlock) Validate() error {
+	return checkLength("divider block_id", b.BlockID, maxBlockIDLength)
+}
+
+func (b *DividerBlock) MarshalJSON() ([]byte, error) {
+	type alias DividerBlock
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*alias
+	}{blockTypeDivider, (*alias)(b)})
+}
+
+type ContextBlock struct {
+	BlockID  string           `json:"block_id,omitempty"`
+	Elements []ContextElement `json:"elements"`
+}
+
+func Context(elements ...ContextElement) *ContextBlock {
+	return &ContextBlock{Elements: elements}
+}
+
+func (b *ContextBlock) Validate() error {
+	if len(b.Elements) == 0 {
+		return fmt.Errorf("context: needs elements")
+	}
+	if len(b.Elements) > maxContextElements {
+		return fmt.Errorf("context: %d elements exceed the limit of %d", len(b.Elements), maxContextElements)
+	}
+	err := checkLength("context block_id", b.BlockID, maxBlockIDLength)
+	if err != nil {
+		return err
+	}
+	for _, e := range b.Elements {
+		err = e.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *ContextBlock) MarshalJSON() ([]byte, error) {
+	type alias ContextBlock
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*alias
+	}{blockTypeContext, (*alias)(b)})
+}
+
+type ImageBlock struct {
+	BlockID  string      `json:"block_id,omitempty"`
+	ImageURL string      `json:"image_url"`
+	AltText  string      `json:"alt_text"`
+	Title    *TextObject `json:"title,omitempty"`
+}
+
+func Image(imageURL, altText string) *ImageBlock {
+	return &ImageBlock{ImageURL: imageURL, AltText: altText}
+}
+
+func (b *ImageBlock) Validate() error {
+	if b.ImageURL == "" || b.AltText == "" {
+		return fmt.Errorf("image: needs image_url and alt_text")
+	}
+	err := checkLength("image block_id", b.BlockID, maxBlockIDLength)
+	if err != nil {
+		return err
+	}
+	err = checkLength("image_url", b.ImageURL, maxImageURLLength)
+	if err != nil {
+		return err
+	}
+	err = checkLength("alt_text", b.AltText, maxAltTextLength)
+	if err != nil {
+		return err
+	}
+	if b.Title != nil {
+		return checkLength("image title", b.Title.Text, maxAltTextLength)
+	}
+	return nil
+}
+
+func (b *ImageBlock) MarshalJSON() ([]byte, error) {
+	type alias ImageBlock
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*alias
+	}{blockTypeImage, (*alias)(b)})
+}
+
+type ActionsBlock struct {
+	BlockID  string    `json:"block_id,omitempty"`
+	Elements []Element `json:"elements"`
+}
+
+func Actions(elements ...Element) *ActionsBlock {
+	return &ActionsBlock{Elements: elements}
+}
+
+func (b *ActionsBlock) Validate() error {
+	if len(b.Elements) == 0 {
+		return fmt.Errorf("actions: needs elements")
+	}
+	if len(b.Elements) > maxActionsElements {
+		return fmt.Errorf("actions: %d elements exceed the limit of %d", len(b.Elements), maxActionsElements)
+	}
+	err := checkLength("actions block_id", b.BlockID, maxBlockIDLength)
+	if err != nil {
+		return err
+	}
+	for _, e := range b.Elements {
+		err = e.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *ActionsBlock) MarshalJSON() ([]byte, error) {
+	type alias ActionsBlock
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*alias
+	}{blockTypeActions, (*alias)(b)})
+}
+
+type ButtonElement struct {
+	Text     *TextObject `json:"text"`
+	ActionID string      `json:"action_id,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	Value    string      `json:"value,omitempty"`
+	Style    string      `json:"style,omitempty"`
+}
+
+func Button(actionID, text, value string) *ButtonElement {
+	return &ButtonElement{Text: PlainText(text), ActionID: actionID, Value: value}
+}
+
+func LinkButton(text, url string) *ButtonElement {
+	return &ButtonElement{Text: PlainText(text), URL: url}
+}
+
+func (e *ButtonElement) WithStyle(style string) *ButtonElement {
+	e.Style = style
+	return e
+}
+
+func (e *ButtonElement) Validate() error {
+	if e.Text == nil || e.Text.Type != textTypePlain {
+		return fmt.Errorf("button: needs plain text")
+	}
+	if e.Style != "" && e.Style != ButtonStylePrimary && e.Style != ButtonStyleDanger {
+		return fmt.Errorf("button: unknown style %q", e.Style)
+	}
+	err := checkLength("button text", e.Text.Text, maxButtonTextLength)
+	if err != nil {
+		return err
+	}
+	err = checkLength("button action_id", e.ActionID, maxActionIDLength)
+	if err != nil {
+		return err
+	}
+	err = checkLength("button value", e.Value, maxButtonValueLength)
+	if err != nil {
+		return err
+	}
+	return checkLength("button url", e.URL, maxButtonURLLength)
+}
+
+func (e *ButtonElement) MarshalJSON() ([]byte, error) {
+	type alias ButtonElement
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*alias
+	}{elementTypeButton, (*alias)(e)})
+}
+
+type ImageElement struct {
+	ImageURL string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+func Thumbnail(imageURL, altText string) *ImageElement {
+	return &ImageElement{ImageURL: imageURL, AltText: altText}
+}
+
+func (e *ImageElement) Validate() error {
+	if e.ImageURL == "" || e.AltText == "" {
+		return fmt.Errorf("image element: needs image_url and alt_text")
+	}
+	err := checkLength("image_url", e.ImageURL, maxImageURLLength)
+	if err != nil {
+		return err
+	}
+	return checkLength("alt_text", e.AltText, maxAltTextLength)
+}
+
+func (e *ImageElement) MarshalJSON() ([]byte, error) {
+	type alias ImageElement
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*alias
+	}{elementTypeImage, (*alias)(e)})
+}
+
+type OptionObject struct {
+	Text        *TextObject `json:"text"`
+	Value       string      `json:"value"`
+	Description *TextObject `json:"description,omitempty"`
+	URL         string      `json:"url,omitempty"`
+}
+
+func Option(text, value string) *OptionObject {
+	return &OptionObject{Text: PlainText(text), Value: value}
+}
+
+func (o *OptionObject) Validate() error {
+	if o.Text == nil || o.Value == "" {
+		return fmt.Errorf("option: needs text and value")
+	}
+	err := checkLength("option text", o.Text.Text, maxOptionTextLength)
+	if err != nil {
+		return err
+	}
+	return checkLength("option value", o.Value, maxOptionValueLength)
+}
+
+type OverflowElement struct {
+	ActionID string          `json:"action_id"`
+	Options  []*OptionObject `json:"options"`
+}
+
+func Overflow(actionID string, options ...*OptionObject) *OverflowElement {
+	return &OverflowElement{ActionID: actionID, Options: options}
+}
+
+func (e *OverflowElement) Validate() error {
+	if len(e.Options) < minOverflowOptions || len(e.Options) > maxOverflowOptions {
+		return fmt.Errorf("overflow: needs %d to %d options, got %d", minOverflowOptions, maxOverflowOptions, len(e.Options))
+	}
+	err := checkLength("overflow action_id", e.ActionID, maxActionIDLength)
+	if err != nil {
+		return err
+	}
+	for _, o := range e.Options {
+		err = o.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *OverflowElement) MarshalJSON() ([]byte, error) {
+	type alias OverflowElement
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		*alias
+	}{elementTypeOverflow, (*alias)(e)})
+}
@@ -0,0 +1,48 @@
+package wasb
+
+import (
+	"strings"
+)
+
+// CommandFunc handles a command addressed to the bot. args is the text
+// after the command name.
+type CommandFunc func(m *Msg, args string) error
+
+// HandleCommand registers a handler for messages of the form
+// "@bot name args", or "name args" in a direct message.
+func (r *Router) HandleCommand(name string, h CommandFunc, opts ...RouteOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addRoute(&messageRoute{name: strings.ToLower(name), command: true, commandHandler: h}, opts)
+}
+
+// addressed reports whether m is meant for the bot: it mentions the bot or
+// is a direct message.
+func addressed(m *Msg, selfID string) bool {
+	return strings.HasPrefix(m.Channel, "D") || selfID != "" && strings.Contains(m.Text, "<@"+selfID+">")
+}
+
+// parseCommand splits a message addressed to the bot into a command name
+// and its arguments.
+func parseCommand(m *Msg, selfID string) (string, string, bool) {
+	text := strings.TrimSpace(m.Text)
+	mention := "<@" + selfID + ">"
+	switch {
+	case selfID != "" && strings.HasPrefix(text, mention):
+		text = strings.TrimSpace(strings.TrimPrefix(text, mention))
+	case strings.HasPrefix(m.Channel, "D"):
+	default:
+		return "", "", false
+	}
+
+	fields := strings.SplitN(text, " ", 2)
+	name := strings.ToLower(fields[0])
+	if name == "" {
+		return "", "", false
+	}
+	args := ""
+	if len(fields) > 1 {
+		args = strings.TrimSpace(fields[1])
+	}
+	return name, args, true
+}
@@ -0,0 +1,223 @@
+package wasb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dialogCancel = "cancel"
+
+var ErrNoReplier = errors.New("router has no replier")
+
+// ReplyFunc handles the awaited reply to a question.
+type ReplyFunc func(reply *Msg) error
+
+// waiter is a pending question. Nothing blocks while waiting: the reply is
+// dispatched like any other message and a timer fires on timeout.
+type waiter struct {
+	channel   string
+	user      string
+	thread    string
+	asked     string
+	command   string
+	handler   ReplyFunc
+	onTimeout func()
+	timer     *time.Timer
+}
+
+// matches accepts the next message from the same user in the same channel
+// and thread, including a thread started off the question.
+func (w *waiter) matches(m *Msg) bool {
+	if m.Channel != w.channel || m.User != w.user {
+		return false
+	}
+	return m.ThreadTS == w.thread || (w.thread == "" && m.ThreadTS == w.asked)
+}
+
+type conversations struct {
+	mu      sync.Mutex
+	waiters map[string]*waiter
+}
+
+func waiterKey(channel, user string) string {
+	return channel + ":" + user
+}
+
+func (c *conversations) find(m *Msg) *waiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.waiters[waiterKey(m.Channel, m.User)]
+	if !ok || !w.matches(m) {
+		return nil
+	}
+	return w
+}
+
+// take removes and returns the waiter for m, if it has not timed out yet.
+func (c *conversations) take(m *Msg) *waiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := waiterKey(m.Channel, m.User)
+	w, ok := c.waiters[key]
+	if !ok || !w.matches(m) {
+		return nil
+	}
+	delete(c.waiters, key)
+	w.timer.Stop()
+	return w
+}
+
+func (c *conversations) add(w *waiter, timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.waiters == nil {
+		c.waiters = make(map[string]*waiter)
+	}
+
+	key := waiterKey(w.channel, w.user)
+	// A new question replaces one still waiting for the same user
+	if old, ok := c.waiters[key]; ok {
+		old.timer.Stop()
+	}
+	c.waiters[key] = w
+	w.timer = time.AfterFunc(timeout, func() {
+		c.mu.Lock()
+		current, ok := c.waiters[key]
+		if ok && current == w {
+			delete(c.waiters, key)
+		}
+		c.mu.Unlock()
+		if ok && current == w && w.onTimeout != nil {
+			w.onTimeout()
+		}
+	})
+}
+
+// Await hands the next message from m's author in m's channel (and thread)
+// to h instead of the usual handlers, once allowed by the ACL and rate
+// limits of the command m ran. onTimeout, if not nil, is called if no reply
+// arrives in time.
+func (r *Router) Await(m *Msg, timeout time.Duration, h ReplyFunc, onTimeout func()) {
+	r.conversations.add(&waiter{
+		channel:   m.Channel,
+		user:      m.User,
+		thread:    m.ThreadTS,
+		asked:     m.TS,
+		command:   m.command,
+		handler:   h,
+		onTimeout: onTimeout,
+	}, timeout)
+}
+
+// Ask replies to m with a question and awaits the answer.
+func (r *Router) Ask(m *Msg, question string, timeout time.Duration, h ReplyFunc, onTimeout func()) error {
+	r.mu.RLock()
+	replier := r.replier
+	r.mu.RUnlock()
+	if replier == nil {
+		return ErrNoReplier
+	}
+
+	err := replier.ReplyText(m, question)
+	if err != nil {
+		return err
+	}
+	r.Await(m, timeout, h, onTimeout)
+	return nil
+}
+
+// DialogStep asks for one value. Answers must be one of Choices if any are
+// given, and pass Validate if set. The error message of a failed
+// validation is shown before asking again.
+type DialogStep struct {
+	Key      string
+	Prompt   string
+	Choices  []string
+	Validate func(answer string) error
+}
+
+func (step *DialogStep) prompt() string {
+	if len(step.Choices) == 0 {
+		return step.Prompt
+	}
+	return fmt.Sprintf("%s (%s)", step.Prompt, strings.Join(step.Choices, ", "))
+}
+
+func (step *DialogStep) check(answer string) error {
+	if len(step.Choices) > 0 {
+		found := false
+		for _, c := range step.Choices {
+			if strings.EqualFold(c, answer) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Please answer one of: %s", strings.Join(step.Choices, ", "))
+		}
+	}
+	if step.Validate != nil {
+		return step.Validate(answer)
+	}
+	return nil
+}
+
+// Dialog is a wizard asking its steps in order. Answering "cancel" stops
+// it. OnDone gets the last answer and all answers by step key.
+type Dialog struct {
+	Steps     []*DialogStep
+	Timeout   time.Duration
+	OnDone    func(last *Msg, answers map[string]string) error
+	OnTimeout func(last *Msg)
+}
+
+func (r *Router) StartDialog(m *Msg, d *Dialog) error {
+	if len(d.Steps) == 0 {
+		return d.OnDone(m, map[string]string{})
+	}
+	return r.askStep(m, d, 0, make(map[string]string))
+}
+
+func (r *Router) askStep(m *Msg, d *Dialog, i int, answers map[string]string) error {
+	step := d.Steps[i]
+	onTimeout := func() {
+		if d.OnTimeout != nil {
+			d.OnTimeout(m)
+		}
+	}
+
+	return r.Ask(m, step.prompt(), d.Timeout, func(reply *Msg) error {
+		answer := strings.TrimSpace(reply.Text)
+		if strings.EqualFold(answer, dialogCancel) {
+			return r.replyText(reply, "Cancelled.")
+		}
+
+		err := step.check(answer)
+		if err != nil {
+			err = r.replyText(reply, err.Error())
+			if err != nil {
+				return err
+			}
+			return r.askStep(reply, d, i, answers)
+		}
+
+		answers[step.Key] = answer
+		if i+1 == len(d.Steps) {
+			return d.OnDone(reply, answers)
+		}
+		return r.askStep(reply, d, i+1, answers)
+	}, onTimeout)
+}
+
+func (r *Router) replyText(m *Msg, text string) error {
+	r.mu.RLock()
+	replier := r.replier
+	r.mu.RUnlock()
+	if replier == nil {
+		return ErrNoReplier
+	}
+	return replier.ReplyText(m, text)
+}
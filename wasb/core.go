@@ -1,8 +1,9 @@
 package wasb
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -20,8 +21,44 @@ const (
 )
 
 type Cfg struct {
-	APIToken string `json:"apitoken"`
-	Workers  int    `json:"workers"`
+	APIToken      string `json:"apitoken"`
+	Workers       int    `json:"workers"`
+	SigningSecret string `json:"signingsecret"`
+	ListenAddr    string `json:"listenaddr"`
+
+	// Metrics are served at /debug/vars on this address only, e.g.
+	// "localhost:6060". Unset, they are not served.
+	DebugAddr string `json:"debugaddr"`
+
+	// Receive events over HTTP, at /events under the workspace's handler,
+	// rather than over the RTM websocket. Needs the signing secret.
+	Events bool `json:"events"`
+
+	// Reply in threads rather than at the channel's top level, with
+	// per-channel overrides
+	ThreadReplies  bool            `json:"threadreplies"`
+	ThreadChannels map[string]bool `json:"threadchannels"`
+
+	// Replies over Slack's length limit are split into at most
+	// SplitMaxParts messages, optionally sent in a thread. Longer ones are
+	// uploaded as a snippet.
+	SplitInThread bool `json:"splitinthread"`
+	SplitMaxParts int  `json:"splitmaxparts"`
+
+	ACL        *ACLCfg        `json:"acl"`
+	RateLimits *RateLimitsCfg `json:"ratelimits"`
+
+	// File to persist the bot's storage to, kept in memory only if empty
+	StoreFile string `json:"storefile"`
+
+	// Name of the workspace and configs of the plugins to run in it, by
+	// plugin name
+	Name    string                     `json:"name"`
+	Plugins map[string]json.RawMessage `json:"plugins"`
+
+	// Several workspaces (each with its own token and workers) served by a
+	// single process
+	Workspaces []*Cfg `json:"workspaces"`
 }
 
 type RespRTMStart struct {
@@ -32,14 +69,53 @@ type RespRTMStart struct {
 }
 
 type RespRTMStartSelf struct {
-	ID string `json:"id"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
 
 type Msg struct {
-	ID      uint64 `json:"id"`
-	Type    string `json:"type"`
-	Channel string `json:"channel"`
-	Text    string `json:"text"`
+	ID             uint64 `json:"id"`
+	Type           string `json:"type"`
+	Channel        string `json:"channel"`
+	User           string `json:"user,omitempty"`
+	BotID          string `json:"bot_id,omitempty"`
+	Subtype        string `json:"subtype,omitempty"`
+	Text           string `json:"text"`
+	Blocks         Blocks `json:"blocks,omitempty"`
+	TS             string `json:"ts,omitempty"`
+	ThreadTS       string `json:"thread_ts,omitempty"`
+	ReplyBroadcast bool   `json:"reply_broadcast,omitempty"`
+
+	ctx context.Context
+
+	// Name of the route handling m. Answers to its questions are checked
+	// as that command.
+	command string
+}
+
+// Context returns the context m is handled in. It is canceled when the bot
+// stops or the handler's deadline passes.
+func (m *Msg) Context() context.Context {
+	if m.ctx != nil {
+		return m.ctx
+	}
+	return context.Background()
+}
+
+// WithContext returns a copy of m handled in ctx.
+func (m *Msg) WithContext(ctx context.Context) *Msg {
+	m2 := *m
+	m2.ctx = ctx
+	return &m2
+}
+
+// ThreadRoot returns the ts of the thread m belongs to, or of m itself if
+// it is a top level message.
+func (m *Msg) ThreadRoot() string {
+	if m.ThreadTS != "" {
+		return m.ThreadTS
+	}
+	return m.TS
 }
 
 type WASB interface {
@@ -94,7 +170,7 @@ func StartRTM(token string) (*RespRTMStart, error) {
 		return nil, err
 	}
 	if !result.OK {
-		return nil, fmt.Errorf(result.Error)
+		return nil, errors.New(result.Error)
 	}
 
 	return &result, nil
@@ -106,19 +182,42 @@ func GetWSConn(url string) (*websocket.Conn, error) {
 }
 
 func Start(wasb WASB, workers int) {
-	// Wait group to keep track of worker cancellation
-	var wg sync.WaitGroup
-
 	// Channel for receiving OS error signals
-	sigs := make(chan os.Signal)
+	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
 	defer close(sigs)
 
+	// Channel for broadcasting "done" signals
+	done := make(chan struct{})
+
+	// Receive OS error signal
+	go func() {
+		<-sigs
+		close(done)
+	}()
+
+	err := Run(wasb, workers, done)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Run is like Start but stops when done is closed instead of on OS
+// signals, so that several bots can run in one process.
+func Run(wasb WASB, workers int, done <-chan struct{}) error {
+	// Wait group to keep track of worker cancellation
+	var wg sync.WaitGroup
+
 	// Channel for receiving messages
 	msgs := make(chan *Msg)
 
-	// Channel for broadcasting "done" signals
-	done := make(chan bool)
+	// Cancel handlers still running when done
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-done
+		cancel()
+	}()
 
 	// Publish messages
 	go func() {
@@ -132,8 +231,14 @@ func Start(wasb WASB, workers int) {
 				if err != nil {
 					continue
 				}
-				if wasb.IsValidMessage(m) {
-					msgs <- m
+				// Drop the bot's own messages, other bots, edits and
+				// deletions unless the bot asks for them
+				if messageFilter(wasb).Accept(m) && wasb.IsValidMessage(m) {
+					select {
+					case msgs <- m:
+					case <-done:
+						return
+					}
 				}
 			}
 		}
@@ -146,8 +251,11 @@ func Start(wasb WASB, workers int) {
 			select {
 			case <-done:
 				return
-			case m := <-msgs:
-				err := wasb.SendMessage(m)
+			case m, ok := <-msgs:
+				if !ok {
+					return
+				}
+				err := wasb.SendMessage(m.WithContext(ctx))
 				if err != nil {
 					continue
 				}
@@ -161,18 +269,10 @@ func Start(wasb WASB, workers int) {
 		go startWorker()
 	}
 
-	// Receive OS error signal
-	<-sigs
-
-	// Close channel to broadcast done signals to all worker goroutines
-	close(done)
-
-	// Wait for goroutines to complete then terminate
+	// Wait for the done signal, then for goroutines to complete
+	<-done
 	wg.Wait()
 
 	// Tear down to complete the process
-	err := wasb.TearDown()
-	if err != nil {
-		panic(err)
-	}
+	return wasb.TearDown()
 }
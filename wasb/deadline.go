@@ -0,0 +1,63 @@
+package wasb
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// progress is the interim reply of a slow handler.
+type progress struct {
+	after time.Duration
+	text  string
+}
+
+// Timeout cancels a handler's context after d, so that the outbound calls
+// it makes with it give up.
+func Timeout(d time.Duration) RouteOption {
+	return func(mr *messageRoute) { mr.timeout = d }
+}
+
+// Progress replies text, e.g. "Still working…", to requests a handler has
+// not answered after d. Slash commands get it as the ack of commands not
+// answered within Slack's deadline.
+func Progress(d time.Duration, text string) RouteOption {
+	return func(mr *messageRoute) { mr.progress = &progress{after: d, text: text} }
+}
+
+// context derives the context a handler runs in from parent.
+func (mr *messageRoute) context(parent context.Context) (context.Context, context.CancelFunc) {
+	if mr.timeout > 0 {
+		return context.WithTimeout(parent, mr.timeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// run calls a message or command handler within its deadline, sending its
+// interim reply if it is slow.
+func (r *Router) run(mr *messageRoute, m *Msg, args string, replier *Replier) error {
+	ctx, cancel := mr.context(m.Context())
+	defer cancel()
+	m = m.WithContext(ctx)
+	m.command = mr.name
+
+	if mr.progress != nil && replier != nil {
+		timer := time.AfterFunc(mr.progress.after, func() {
+			err := replier.ReplyText(m, mr.progress.text)
+			if err != nil {
+				log.Printf("Error sending progress reply: %s", err)
+			}
+		})
+		defer timer.Stop()
+	}
+
+	if mr.command {
+		return mr.commandHandler(m, args)
+	}
+	return mr.handler(m)
+}
+
+// Stop cancels the slash commands still being handled.
+func (r *Router) Stop() {
+	r.cancel()
+}
@@ -0,0 +1,184 @@
+package wasb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	slackSignatureVersion = "v0"
+	slackHeaderSignature  = "X-Slack-Signature"
+	slackHeaderTimestamp  = "X-Slack-Request-Timestamp"
+
+	eventTypeURLVerification = "url_verification"
+	eventTypeCallback        = "event_callback"
+
+	// Slack expects an ack within 3 seconds, leave some room for the network
+	eventsAckTimeout   = 2500 * time.Millisecond
+	eventsReplayWindow = 5 * time.Minute
+	eventsMaxBodySize  = 1 << 20
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid request signature")
+	ErrStaleRequest     = errors.New("request timestamp outside replay window")
+	ErrReceiverClosed   = errors.New("events receiver closed")
+	ErrNoSigningSecret  = errors.New("no signing secret configured")
+)
+
+type EventCallback struct {
+	Token     string          `json:"token"`
+	TeamID    string          `json:"team_id"`
+	APIAppID  string          `json:"api_app_id"`
+	Type      string          `json:"type"`
+	Challenge string          `json:"challenge"`
+	EventID   string          `json:"event_id"`
+	EventTime int64           `json:"event_time"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// EventsReceiver is an http.Handler for Events API callbacks. It also
+// implements ReceiveMessage so that bots can feed it to Start in place of
+// an RTM websocket connection.
+type EventsReceiver struct {
+	signingSecret string
+	msgs          chan *Msg
+	closed        chan struct{}
+	closeOnce     sync.Once
+	now           func() time.Time
+}
+
+// NewEventsReceiver refuses to run without a signing secret: anyone could
+// sign requests with an empty one.
+func NewEventsReceiver(signingSecret string, buffer int) (*EventsReceiver, error) {
+	if signingSecret == "" {
+		return nil, ErrNoSigningSecret
+	}
+	return &EventsReceiver{
+		signingSecret: signingSecret,
+		msgs:          make(chan *Msg, buffer),
+		closed:        make(chan struct{}),
+		now:           time.Now,
+	}, nil
+}
+
+func (er *EventsReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := VerifyRequest(r, er.signingSecret, er.now())
+	if err != nil {
+		log.Printf("Rejecting event callback: %s", err)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var cb EventCallback
+	err = json.Unmarshal(body, &cb)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	switch cb.Type {
+	case eventTypeURLVerification:
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, cb.Challenge)
+		return
+	case eventTypeCallback:
+	default:
+		// Ack anything else so that Slack does not keep retrying
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var m Msg
+	err = json.Unmarshal(cb.Event, &m)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	// Hand the event over to the workers, but never hold the ack past the
+	// deadline. A 503 makes Slack retry the delivery later.
+	timer := time.NewTimer(eventsAckTimeout)
+	defer timer.Stop()
+	select {
+	case er.msgs <- &m:
+		w.WriteHeader(http.StatusOK)
+	case <-timer.C:
+		log.Printf("Event queue full, asking Slack to retry (event: %s)", cb.EventID)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	case <-er.closed:
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}
+}
+
+func (er *EventsReceiver) ReceiveMessage() (*Msg, error) {
+	select {
+	case m := <-er.msgs:
+		return m, nil
+	case <-er.closed:
+		return nil, ErrReceiverClosed
+	}
+}
+
+func (er *EventsReceiver) Close() error {
+	er.closeOnce.Do(func() {
+		close(er.closed)
+	})
+	return nil
+}
+
+// VerifyRequest checks the signature Slack attaches to every HTTP request
+// it sends and returns the request body. Without a signing secret every
+// request is rejected.
+func VerifyRequest(r *http.Request, signingSecret string, now time.Time) ([]byte, error) {
+	if signingSecret == "" {
+		return nil, ErrNoSigningSecret
+	}
+	ts := r.Header.Get(slackHeaderTimestamp)
+	sig := r.Header.Get(slackHeaderSignature)
+	if ts == "" || sig == "" {
+		return nil, ErrInvalidSignature
+	}
+
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	delta := now.Sub(time.Unix(secs, 0))
+	if delta > eventsReplayWindow || delta < -eventsReplayWindow {
+		return nil, ErrStaleRequest
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, eventsMaxBodySize))
+	if err != nil {
+		return nil, err
+	}
+	// Let later handlers read the body again
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	fmt.Fprintf(mac, "%s:%s:", slackSignatureVersion, ts)
+	mac.Write(body)
+	expected := slackSignatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return nil, ErrInvalidSignature
+	}
+
+	return body, nil
+}
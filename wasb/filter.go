@@ -0,0 +1,84 @@
+package wasb
+
+const (
+	SubtypeBotMessage     = "bot_message"
+	SubtypeMessageChanged = "message_changed"
+	SubtypeMessageDeleted = "message_deleted"
+)
+
+// Filter drops messages before they reach IsValidMessage. By default the
+// bot's own messages, messages from other bots, edits and deletions are
+// dropped so that bots do not end up talking to themselves or each other.
+type Filter struct {
+	SelfID       string
+	AllowSelf    bool
+	AllowBots    bool
+	AllowEdits   bool
+	AllowDeletes bool
+}
+
+// Filterer is implemented by bots that know their own identity or need
+// messages the default filter drops.
+type Filterer interface {
+	MessageFilter() *Filter
+}
+
+// Identifier is implemented by bots that know their own user ID, so that
+// their messages are dropped even without a filter of their own.
+type Identifier interface {
+	SelfID() string
+}
+
+func (f *Filter) Accept(m *Msg) bool {
+	if f.SelfID != "" && m.User == f.SelfID && !f.AllowSelf {
+		return false
+	}
+	if (m.Subtype == SubtypeBotMessage || m.BotID != "") && !f.AllowBots {
+		return false
+	}
+	if m.Subtype == SubtypeMessageChanged && !f.AllowEdits {
+		return false
+	}
+	if m.Subtype == SubtypeMessageDeleted && !f.AllowDeletes {
+		return false
+	}
+	return true
+}
+
+// merge widens f so that it accepts everything o accepts.
+func (f *Filter) merge(o *Filter) {
+	f.AllowSelf = f.AllowSelf || o.AllowSelf
+	f.AllowBots = f.AllowBots || o.AllowBots
+	f.AllowEdits = f.AllowEdits || o.AllowEdits
+	f.AllowDeletes = f.AllowDeletes || o.AllowDeletes
+}
+
+func AllowSelf() RouteOption {
+	return func(mr *messageRoute) { mr.filter.AllowSelf = true }
+}
+
+func AllowBots() RouteOption {
+	return func(mr *messageRoute) { mr.filter.AllowBots = true }
+}
+
+func AllowEdits() RouteOption {
+	return func(mr *messageRoute) { mr.filter.AllowEdits = true }
+}
+
+func AllowDeletes() RouteOption {
+	return func(mr *messageRoute) { mr.filter.AllowDeletes = true }
+}
+
+// messageFilter returns the filter of the bot as it is now, as handlers
+// and its identity may change while it runs.
+func messageFilter(wasb WASB) *Filter {
+	f := &Filter{}
+	if filterer, ok := wasb.(Filterer); ok {
+		filter := *filterer.MessageFilter()
+		f = &filter
+	}
+	if identifier, ok := wasb.(Identifier); ok && f.SelfID == "" {
+		f.SelfID = identifier.SelfID()
+	}
+	return f
+}
@@ -0,0 +1,145 @@
+package wasb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dysfn/wasb/wasb/mrkdwn"
+)
+
+const helpCommand = "help"
+
+// commandInfo is the metadata help is generated from.
+type commandInfo struct {
+	usage       string
+	description string
+	examples    []string
+	hidden      bool
+}
+
+// Usage shows how to invoke a handler, e.g. "tldr <url>".
+func Usage(usage string) RouteOption {
+	return func(mr *messageRoute) { mr.usage = usage }
+}
+
+func Description(description string) RouteOption {
+	return func(mr *messageRoute) { mr.description = description }
+}
+
+func Example(examples ...string) RouteOption {
+	return func(mr *messageRoute) { mr.examples = append(mr.examples, examples...) }
+}
+
+// Hidden leaves a handler out of the help.
+func Hidden() RouteOption {
+	return func(mr *messageRoute) { mr.hidden = true }
+}
+
+type helpEntry struct {
+	name string
+	commandInfo
+}
+
+// helpEntries lists named handlers, commands and slash commands with their
+// metadata, sorted by name.
+func (r *Router) helpEntries() []*helpEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []*helpEntry
+	for _, mr := range r.messages {
+		if mr.name == "" || mr.hidden {
+			continue
+		}
+		entries = append(entries, &helpEntry{name: mr.name, commandInfo: mr.commandInfo})
+	}
+	for name, mr := range r.slashRoutes {
+		if mr.hidden {
+			continue
+		}
+		entries = append(entries, &helpEntry{name: name, commandInfo: mr.commandInfo})
+	}
+	sort.Sort(helpEntriesByName(entries))
+	return entries
+}
+
+type helpEntriesByName []*helpEntry
+
+func (es helpEntriesByName) Len() int           { return len(es) }
+func (es helpEntriesByName) Less(i, j int) bool { return es[i].name < es[j].name }
+func (es helpEntriesByName) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+
+func (e *helpEntry) usage() string {
+	if e.commandInfo.usage != "" {
+		return e.commandInfo.usage
+	}
+	return e.name
+}
+
+// permission notes the role a command requires, if any.
+func (r *Router) permission(name string) string {
+	r.mu.RLock()
+	acl := r.acl
+	r.mu.RUnlock()
+	if acl == nil {
+		return ""
+	}
+	if role := acl.RequiredRole(name); role != "" {
+		return fmt.Sprintf(" _(%s only)_", mrkdwn.Escape(role))
+	}
+	return ""
+}
+
+// Help renders the list of everything the bot can do, or the details of
+// one command.
+func (r *Router) Help(command string) string {
+	entries := r.helpEntries()
+	var b bytes.Buffer
+
+	if command != "" {
+		for _, e := range entries {
+			if e.name != command && e.name != "/"+command {
+				continue
+			}
+			fmt.Fprintf(&b, "%s%s\n", mrkdwn.Code(e.usage()), r.permission(e.name))
+			if e.description != "" {
+				fmt.Fprintf(&b, "%s\n", mrkdwn.Escape(e.description))
+			}
+			if len(e.examples) > 0 {
+				b.WriteString("\n*Examples*\n")
+				for _, ex := range e.examples {
+					fmt.Fprintf(&b, "• %s\n", mrkdwn.Code(ex))
+				}
+			}
+			return strings.TrimSpace(b.String())
+		}
+		return fmt.Sprintf("I don't know %s. Try %s.", mrkdwn.Code(command), mrkdwn.Code(helpCommand))
+	}
+
+	if len(entries) == 0 {
+		return "I don't have any commands yet."
+	}
+	b.WriteString("Here is what I can do:\n")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "• %s", mrkdwn.Code(e.usage()))
+		if e.description != "" {
+			fmt.Fprintf(&b, " — %s", mrkdwn.Escape(e.description))
+		}
+		fmt.Fprintf(&b, "%s\n", r.permission(e.name))
+	}
+	fmt.Fprintf(&b, "Type %s for details.", mrkdwn.Code(helpCommand+" <command>"))
+	return b.String()
+}
+
+func (r *Router) helpRoute() *messageRoute {
+	return &messageRoute{
+		name:    helpCommand,
+		command: true,
+		filter:  &Filter{},
+		commandHandler: func(m *Msg, args string) error {
+			return r.replyText(m, r.Help(strings.ToLower(strings.TrimSpace(args))))
+		},
+	}
+}
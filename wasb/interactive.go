@@ -0,0 +1,239 @@
+package wasb
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	InteractionBlockActions   = "block_actions"
+	InteractionViewSubmission = "view_submission"
+	InteractionViewClosed     = "view_closed"
+)
+
+type InteractionTeam struct {
+	ID     string `json:"id"`
+	Domain string `json:"domain"`
+}
+
+type InteractionUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	TeamID   string `json:"team_id"`
+}
+
+type InteractionChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type SelectedOption struct {
+	Value string `json:"value"`
+}
+
+type Action struct {
+	ActionID        string            `json:"action_id"`
+	BlockID         string            `json:"block_id"`
+	Type            string            `json:"type"`
+	Value           string            `json:"value"`
+	ActionTS        string            `json:"action_ts"`
+	SelectedOption  *SelectedOption   `json:"selected_option"`
+	SelectedOptions []*SelectedOption `json:"selected_options"`
+	SelectedUser    string            `json:"selected_user"`
+	SelectedChannel string            `json:"selected_channel"`
+	SelectedDate    string            `json:"selected_date"`
+}
+
+type ActionValue struct {
+	Type            string            `json:"type"`
+	Value           string            `json:"value"`
+	SelectedOption  *SelectedOption   `json:"selected_option"`
+	SelectedOptions []*SelectedOption `json:"selected_options"`
+	SelectedUser    string            `json:"selected_user"`
+	SelectedChannel string            `json:"selected_channel"`
+	SelectedDate    string            `json:"selected_date"`
+}
+
+type ViewState struct {
+	// Values by block ID, then by action ID
+	Values map[string]map[string]*ActionValue `json:"values"`
+}
+
+type View struct {
+	ID              string    `json:"id"`
+	CallbackID      string    `json:"callback_id"`
+	PrivateMetadata string    `json:"private_metadata"`
+	State           ViewState `json:"state"`
+}
+
+type Interaction struct {
+	Type        string             `json:"type"`
+	Team        InteractionTeam    `json:"team"`
+	User        InteractionUser    `json:"user"`
+	Channel     InteractionChannel `json:"channel"`
+	TriggerID   string             `json:"trigger_id"`
+	ResponseURL string             `json:"response_url"`
+	Actions     []*Action          `json:"actions"`
+	View        *View              `json:"view"`
+	Message     *Msg               `json:"message"`
+
+	ctx context.Context
+}
+
+// Context returns the context i is handled in. It is canceled when the
+// router stops or the handler returns.
+func (i *Interaction) Context() context.Context {
+	if i.ctx != nil {
+		return i.ctx
+	}
+	return context.Background()
+}
+
+// ViewErrors can be returned by a view submission handler to show
+// validation errors next to the offending blocks instead of closing the
+// modal.
+type ViewErrors map[string]string
+
+func (ve ViewErrors) Error() string {
+	return "invalid view submission"
+}
+
+// InteractionHandlerFunc handles a button click or menu selection (a is the
+// action that fired) or a modal submission (a is nil). The response, if
+// any, is posted to the payload's response_url.
+type InteractionHandlerFunc func(i *Interaction, a *Action) (*Response, error)
+
+// InteractionHandler returns the http.Handler to use as the app's
+// Interactivity Request URL.
+func (r *Router) InteractionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		_, err := VerifyRequest(req, r.signingSecret, time.Now())
+		if err != nil {
+			log.Printf("Rejecting interactive payload: %s", err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		err = req.ParseForm()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		var i Interaction
+		err = json.Unmarshal([]byte(req.PostForm.Get("payload")), &i)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		switch i.Type {
+		case InteractionBlockActions:
+			r.handleActions(w, req, &i)
+		case InteractionViewSubmission, InteractionViewClosed:
+			r.handleView(w, req, &i)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// handleActions runs the handlers of the actions, each checked against
+// the ACL and rate limits as a command named after its action ID.
+func (r *Router) handleActions(w http.ResponseWriter, req *http.Request, i *Interaction) {
+	// Slack ignores the body of block action acks, responses always go
+	// to response_url
+	w.WriteHeader(http.StatusOK)
+
+	for _, a := range i.Actions {
+		r.mu.RLock()
+		h, ok := r.actions[a.ActionID]
+		r.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		if resp := r.denied(req.Context(), i.User.ID, i.Channel.ID, a.ActionID); resp != nil {
+			if i.ResponseURL != "" {
+				go r.PostResponse(i.ResponseURL, resp)
+			}
+			continue
+		}
+		if resp, limited := r.limited(i.User.ID, i.Channel.ID, a.ActionID); limited {
+			if resp != nil && i.ResponseURL != "" {
+				go r.PostResponse(i.ResponseURL, resp)
+			}
+			continue
+		}
+
+		go func(i Interaction, a *Action) {
+			ctx, cancel := context.WithCancel(r.ctx)
+			defer cancel()
+			i.ctx = ctx
+			resp, err := h(&i, a)
+			if err != nil {
+				log.Printf("Action handler failed (action_id: %s): %s", a.ActionID, err)
+				resp = errorResponse
+			}
+			if resp == nil || i.ResponseURL == "" {
+				return
+			}
+			err = r.PostResponse(i.ResponseURL, resp)
+			if err != nil {
+				log.Printf("Error posting action response: %s", err)
+			}
+		}(*i, a)
+	}
+}
+
+// handleView runs the handler of a modal, checked against the ACL as a
+// command named after its callback ID.
+func (r *Router) handleView(w http.ResponseWriter, req *http.Request, i *Interaction) {
+	if i.View == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	r.mu.RLock()
+	h, ok := r.views[i.View.CallbackID]
+	r.mu.RUnlock()
+	if !ok || r.denied(req.Context(), i.User.ID, i.Channel.ID, i.View.CallbackID) != nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Validation errors have to be part of the ack, so wait for the
+	// handler up to the deadline
+	errs := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithCancel(r.ctx)
+		defer cancel()
+		i.ctx = ctx
+		_, err := h(i, nil)
+		errs <- err
+	}()
+
+	timer := time.NewTimer(eventsAckTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-errs:
+		if ve, ok := err.(ViewErrors); ok {
+			writeResponse(w, map[string]interface{}{
+				"response_action": "errors",
+				"errors":          ve,
+			})
+			return
+		}
+		if err != nil {
+			log.Printf("View handler failed (callback_id: %s): %s", i.View.CallbackID, err)
+		}
+		w.WriteHeader(http.StatusOK)
+	case <-timer.C:
+		w.WriteHeader(http.StatusOK)
+	}
+}
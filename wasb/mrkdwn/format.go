@@ -0,0 +1,64 @@
+package mrkdwn
+
+import (
+	"strings"
+)
+
+func Bold(s string) string {
+	return "*" + Escape(s) + "*"
+}
+
+func Italic(s string) string {
+	return "_" + Escape(s) + "_"
+}
+
+func Strike(s string) string {
+	return "~" + Escape(s) + "~"
+}
+
+func Code(s string) string {
+	return "`" + Escape(strings.Replace(s, "`", "'", -1)) + "`"
+}
+
+func CodeBlock(s string) string {
+	return "```" + Escape(strings.Replace(s, "```", "'''", -1)) + "```"
+}
+
+func Quote(s string) string {
+	lines := strings.Split(Escape(s), "\n")
+	return "> " + strings.Join(lines, "\n> ")
+}
+
+// Link formats a link. The label is optional.
+func Link(url, label string) string {
+	// "|" would end the URL early, it has to be percent-encoded
+	url = strings.Replace(Escape(url), "|", "%7C", -1)
+	if label == "" {
+		return "<" + url + ">"
+	}
+	return "<" + url + "|" + Escape(label) + ">"
+}
+
+func User(id string) string {
+	return "<@" + id + ">"
+}
+
+func Channel(id string) string {
+	return "<#" + id + ">"
+}
+
+func Here() string {
+	return "<!here>"
+}
+
+func Everyone() string {
+	return "<!everyone>"
+}
+
+func UserGroup(id string) string {
+	return "<!subteam^" + id + ">"
+}
+
+func Emoji(name string) string {
+	return ":" + strings.Trim(name, ":") + ":"
+}
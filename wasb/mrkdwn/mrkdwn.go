@@ -0,0 +1,220 @@
+// Package mrkdwn parses and formats Slack's message markup.
+//
+// See https://api.slack.com/reference/surfaces/formatting
+package mrkdwn
+
+import (
+	"bytes"
+	"strings"
+)
+
+type Kind int
+
+const (
+	TextToken Kind = iota
+	UserToken
+	ChannelToken
+	SpecialToken
+	LinkToken
+	EmojiToken
+	CodeToken
+	CodeBlockToken
+)
+
+var kindNames = map[Kind]string{
+	TextToken:      "text",
+	UserToken:      "user",
+	ChannelToken:   "channel",
+	SpecialToken:   "special",
+	LinkToken:      "link",
+	EmojiToken:     "emoji",
+	CodeToken:      "code",
+	CodeBlockToken: "codeblock",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Token is a piece of a message. Raw is the markup as it appeared in the
+// message. Value is the unescaped text of plain text tokens, the user or
+// channel ID of mentions and channel links, the keyword (here, channel,
+// subteam^S123...) for special mentions, the URL for links, the name for
+// emoji and the content of code. Label is the optional text after the "|".
+type Token struct {
+	Kind  Kind
+	Raw   string
+	Value string
+	Label string
+}
+
+var (
+	escaper   = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	unescaper = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+)
+
+// Escape makes text safe to send as mrkdwn by escaping the three control
+// characters Slack requires to be encoded.
+func Escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func Unescape(s string) string {
+	return unescaper.Replace(s)
+}
+
+// Parse splits a message into tokens. Adjacent plain text is merged into a
+// single text token.
+func Parse(s string) []Token {
+	var tokens []Token
+	var text bytes.Buffer
+	flush := func() {
+		if text.Len() > 0 {
+			raw := text.String()
+			tokens = append(tokens, Token{Kind: TextToken, Raw: raw, Value: Unescape(raw)})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		var t Token
+		var n int
+		switch s[i] {
+		case '<':
+			t, n = parseAngle(s[i:])
+		case '`':
+			t, n = parseCode(s[i:])
+		case ':':
+			if i == 0 || !isWordByte(s[i-1]) {
+				t, n = parseEmoji(s[i:])
+			}
+		}
+		if n == 0 {
+			text.WriteByte(s[i])
+			i++
+			continue
+		}
+		flush()
+		tokens = append(tokens, t)
+		i += n
+	}
+	flush()
+
+	return tokens
+}
+
+func parseAngle(s string) (Token, int) {
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		return Token{}, 0
+	}
+	raw := s[:end+1]
+	inner := s[1:end]
+	if inner == "" || strings.IndexByte(inner, '<') >= 0 {
+		return Token{}, 0
+	}
+
+	value, label := inner, ""
+	if bar := strings.IndexByte(inner, '|'); bar >= 0 {
+		value, label = inner[:bar], Unescape(inner[bar+1:])
+	}
+
+	t := Token{Raw: raw, Label: label}
+	switch value[0] {
+	case '@':
+		t.Kind, t.Value = UserToken, value[1:]
+	case '#':
+		t.Kind, t.Value = ChannelToken, value[1:]
+	case '!':
+		t.Kind, t.Value = SpecialToken, value[1:]
+	default:
+		t.Kind, t.Value = LinkToken, Unescape(value)
+	}
+	return t, len(raw)
+}
+
+func parseCode(s string) (Token, int) {
+	if strings.HasPrefix(s, "```") {
+		end := strings.Index(s[3:], "```")
+		if end < 0 {
+			return Token{}, 0
+		}
+		raw := s[:end+6]
+		return Token{Kind: CodeBlockToken, Raw: raw, Value: Unescape(s[3 : end+3])}, len(raw)
+	}
+
+	end := strings.IndexByte(s[1:], '`')
+	if end <= 0 {
+		return Token{}, 0
+	}
+	raw := s[:end+2]
+	if strings.IndexByte(raw, '\n') >= 0 {
+		return Token{}, 0
+	}
+	return Token{Kind: CodeToken, Raw: raw, Value: Unescape(s[1 : end+1])}, len(raw)
+}
+
+func parseEmoji(s string) (Token, int) {
+	end := 1
+	letters := false
+	for end < len(s) && isEmojiByte(s[end]) {
+		if s[end] < '0' || s[end] > '9' {
+			letters = true
+		}
+		end++
+	}
+	if end == 1 || end >= len(s) || s[end] != ':' || !letters {
+		return Token{}, 0
+	}
+	raw := s[:end+1]
+	return Token{Kind: EmojiToken, Raw: raw, Value: s[1:end]}, len(raw)
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+func isEmojiByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '_' || b == '-' || b == '+' || b == '\''
+}
+
+// Plain renders tokens as text readable outside of Slack. Mentions without
+// a label are rendered by ID.
+func Plain(tokens []Token) string {
+	var b bytes.Buffer
+	for _, t := range tokens {
+		switch t.Kind {
+		case TextToken, CodeToken, CodeBlockToken:
+			b.WriteString(t.Value)
+		case UserToken:
+			b.WriteString("@" + labelOr(t, t.Value))
+		case ChannelToken:
+			b.WriteString("#" + labelOr(t, t.Value))
+		case SpecialToken:
+			b.WriteString("@" + labelOr(t, strings.SplitN(t.Value, "^", 2)[0]))
+		case LinkToken:
+			b.WriteString(labelOr(t, strings.TrimPrefix(t.Value, "mailto:")))
+		case EmojiToken:
+			b.WriteString(t.Raw)
+		}
+	}
+	return b.String()
+}
+
+func labelOr(t Token, fallback string) string {
+	if t.Label != "" {
+		return strings.TrimPrefix(t.Label, "@")
+	}
+	return fallback
+}
+
+// Links returns the URL tokens of a message in order of appearance.
+func Links(s string) []Token {
+	var links []Token
+	for _, t := range Parse(s) {
+		if t.Kind == LinkToken {
+			links = append(links, t)
+		}
+	}
+	return links
+}
@@ -0,0 +1,145 @@
+package mrkdwn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text   string
+		tokens []Token
+	}{
+		{"", nil},
+		{"a &amp; b &lt;c&gt;", []Token{
+			{Kind: TextToken, Raw: "a &amp; b &lt;c&gt;", Value: "a & b <c>"},
+		}},
+		{"hi <@U123>", []Token{
+			{Kind: TextToken, Raw: "hi ", Value: "hi "},
+			{Kind: UserToken, Raw: "<@U123>", Value: "U123"},
+		}},
+		{"<@U123|bob>", []Token{
+			{Kind: UserToken, Raw: "<@U123|bob>", Value: "U123", Label: "bob"},
+		}},
+		{"<#C123>", []Token{
+			{Kind: ChannelToken, Raw: "<#C123>", Value: "C123"},
+		}},
+		{"<#C123|general>", []Token{
+			{Kind: ChannelToken, Raw: "<#C123|general>", Value: "C123", Label: "general"},
+		}},
+		{"<!here> <!subteam^S123|@ops>", []Token{
+			{Kind: SpecialToken, Raw: "<!here>", Value: "here"},
+			{Kind: TextToken, Raw: " ", Value: " "},
+			{Kind: SpecialToken, Raw: "<!subteam^S123|@ops>", Value: "subteam^S123", Label: "@ops"},
+		}},
+		{"<https://example.com/?a=1&amp;b=2>", []Token{
+			{Kind: LinkToken, Raw: "<https://example.com/?a=1&amp;b=2>", Value: "https://example.com/?a=1&b=2"},
+		}},
+		{"<https://example.com|R&amp;D>", []Token{
+			{Kind: LinkToken, Raw: "<https://example.com|R&amp;D>", Value: "https://example.com", Label: "R&D"},
+		}},
+		{"*bold <https://example.com|_link_>*", []Token{
+			{Kind: TextToken, Raw: "*bold ", Value: "*bold "},
+			{Kind: LinkToken, Raw: "<https://example.com|_link_>", Value: "https://example.com", Label: "_link_"},
+			{Kind: TextToken, Raw: "*", Value: "*"},
+		}},
+		{"_see `<@U123>`_", []Token{
+			{Kind: TextToken, Raw: "_see ", Value: "_see "},
+			{Kind: CodeToken, Raw: "`<@U123>`", Value: "<@U123>"},
+			{Kind: TextToken, Raw: "_", Value: "_"},
+		}},
+		{"```a &lt; b\n:ok:```", []Token{
+			{Kind: CodeBlockToken, Raw: "```a &lt; b\n:ok:```", Value: "a < b\n:ok:"},
+		}},
+		{"ok :+1: 10:30", []Token{
+			{Kind: TextToken, Raw: "ok ", Value: "ok "},
+			{Kind: EmojiToken, Raw: ":+1:", Value: "+1"},
+			{Kind: TextToken, Raw: " 10:30", Value: " 10:30"},
+		}},
+		{"a < b", []Token{
+			{Kind: TextToken, Raw: "a < b", Value: "a < b"},
+		}},
+		{"<<@U123>", []Token{
+			{Kind: TextToken, Raw: "<", Value: "<"},
+			{Kind: UserToken, Raw: "<@U123>", Value: "U123"},
+		}},
+		{"<> `open", []Token{
+			{Kind: TextToken, Raw: "<> `open", Value: "<> `open"},
+		}},
+	}
+
+	for _, test := range tests {
+		tokens := Parse(test.text)
+		if !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("%q: got tokens %+v, want %+v", test.text, tokens, test.tokens)
+		}
+	}
+}
+
+func TestPlain(t *testing.T) {
+	tests := []struct {
+		text  string
+		plain string
+	}{
+		{"hi <@U123>, <@U456|bob>", "hi @U123, @bob"},
+		{"in <#C123> and <#C456|general>", "in #C123 and #general"},
+		{"<!here> <!subteam^S123> <!subteam^S123|@ops>", "@here @subteam @ops"},
+		{"<https://example.com> <https://example.com|site>", "https://example.com site"},
+		{"<mailto:bob@example.com>", "bob@example.com"},
+		{"*bold <https://example.com|_link_>*", "*bold _link_*"},
+		{"`a &lt; b` &amp; :wave:", "a < b & :wave:"},
+		{"a < b", "a < b"},
+	}
+
+	for _, test := range tests {
+		plain := Plain(Parse(test.text))
+		if plain != test.plain {
+			t.Errorf("%q: got %q, want %q", test.text, plain, test.plain)
+		}
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		text  string
+		links []string
+	}{
+		{"no links, <@U123> <#C123>", nil},
+		{"<https://a.example> and <https://b.example|b>", []string{"https://a.example", "https://b.example"}},
+		{"`<https://a.example>` <https://b.example", nil},
+		{"_<https://a.example/?x=1&amp;y=2>_", []string{"https://a.example/?x=1&y=2"}},
+	}
+
+	for _, test := range tests {
+		var links []string
+		for _, l := range Links(test.text) {
+			links = append(links, l.Value)
+		}
+		if !reflect.DeepEqual(links, test.links) {
+			t.Errorf("%q: got links %q, want %q", test.text, links, test.links)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		text    string
+		escaped string
+	}{
+		{"plain", "plain"},
+		{"a < b > c", "a &lt; b &gt; c"},
+		{"R&D", "R&amp;D"},
+		{"&lt;", "&amp;lt;"},
+		{"<@U123>", "&lt;@U123&gt;"},
+	}
+
+	for _, test := range tests {
+		escaped := Escape(test.text)
+		if escaped != test.escaped {
+			t.Errorf("%q: got %q, want %q", test.text, escaped, test.escaped)
+		}
+		if text := Unescape(escaped); text != test.text {
+			t.Errorf("%q: unescaped to %q", test.text, text)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package wasb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// Services are what a workspace shares with its plugins.
+type Services struct {
+	Workspace string
+	Self      *RespRTMStartSelf
+	Log       *log.Logger
+	Store     Store
+	API       *API
+	Replier   *Replier
+	Scheduler *Scheduler
+}
+
+// Plugin is a feature a workspace can enable by config. A workspace
+// creates one instance per enabled plugin and calls, in order:
+//
+//   - Init with the plugin's config and the workspace's services
+//   - Register to add its handlers to the workspace's router
+//   - Start once the workspace is running
+//   - Stop when it tears down
+type Plugin interface {
+	Name() string
+	Init(cfg json.RawMessage, svc *Services) error
+	Register(r *Router)
+	Start() error
+	Stop() error
+}
+
+var (
+	pluginsMu sync.RWMutex
+	plugins   = make(map[string]func() Plugin)
+)
+
+// RegisterPlugin makes a plugin available under name, typically from the
+// plugin package's init function. Binaries compile plugins in by importing
+// their packages. It panics if name is already taken.
+func RegisterPlugin(name string, newPlugin func() Plugin) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	if newPlugin == nil {
+		panic("wasb: RegisterPlugin with nil constructor for " + name)
+	}
+	if _, dup := plugins[name]; dup {
+		panic("wasb: RegisterPlugin called twice for " + name)
+	}
+	plugins[name] = newPlugin
+}
+
+// PluginNames returns the names of the registered plugins, sorted.
+func PluginNames() []string {
+	pluginsMu.RLock()
+	defer pluginsMu.RUnlock()
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPlugin returns a new instance of the plugin registered under name.
+func NewPlugin(name string) (Plugin, error) {
+	pluginsMu.RLock()
+	newPlugin, ok := plugins[name]
+	pluginsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin %q (registered: %v)", name, PluginNames())
+	}
+	return newPlugin(), nil
+}
+
+// BasePlugin can be embedded by plugins that have nothing to do on Start
+// or Stop.
+type BasePlugin struct{}
+
+func (BasePlugin) Start() error { return nil }
+func (BasePlugin) Stop() error  { return nil }
@@ -0,0 +1,205 @@
+package wasb
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitDrop  = "drop"
+	RateLimitReact = "react"
+	RateLimitReply = "reply"
+
+	defaultRateLimitEmoji   = "hourglass_flowing_sand"
+	defaultRateLimitMessage = "Easy there! Please wait %s before trying again."
+	noRefillMessage         = "Easy there! You have used up your tries, they are not renewed."
+
+	// Wait of buckets that are never refilled
+	noRefill = time.Duration(math.MaxInt64)
+
+	rateLimitSweepSize = 10000
+)
+
+// RateLimitCfg is a token bucket refilled at PerMinute tokens a minute and
+// holding at most Burst tokens, 1 if zero. Buckets with no PerMinute are
+// never refilled.
+type RateLimitCfg struct {
+	PerMinute float64 `json:"perminute"`
+	Burst     int     `json:"burst"`
+}
+
+func (cfg *RateLimitCfg) burst() float64 {
+	if cfg.Burst < 1 {
+		return 1
+	}
+	return float64(cfg.Burst)
+}
+
+// RateLimitsCfg limits how often each user, each channel and each command
+// can trigger the bot. OnExceed is one of "drop" (the default), "react"
+// (with Emoji) or "reply" (with Message, where %s is the time to wait).
+type RateLimitsCfg struct {
+	User     *RateLimitCfg            `json:"user"`
+	Channel  *RateLimitCfg            `json:"channel"`
+	Commands map[string]*RateLimitCfg `json:"commands"`
+	OnExceed string                   `json:"onexceed"`
+	Emoji    string                   `json:"emoji"`
+	Message  string                   `json:"message"`
+}
+
+type bucket struct {
+	cfg    *RateLimitCfg
+	tokens float64
+	last   time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Minutes() * b.cfg.PerMinute
+	b.tokens = math.Min(b.tokens, b.cfg.burst())
+	b.last = now
+}
+
+// wait returns how long until the bucket has a token.
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	if b.cfg.PerMinute <= 0 {
+		return noRefill
+	}
+	return time.Duration((1 - b.tokens) / b.cfg.PerMinute * float64(time.Minute))
+}
+
+type RateLimiter struct {
+	cfg     *RateLimitsCfg
+	api     *API
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+// NewRateLimiter returns a limiter for cfg. api is used to react to
+// messages over the limit and may be nil unless OnExceed is "react".
+func NewRateLimiter(cfg *RateLimitsCfg, api *API) *RateLimiter {
+	if cfg == nil {
+		cfg = &RateLimitsCfg{}
+	}
+	return &RateLimiter{
+		cfg:     cfg,
+		api:     api,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the user's, the channel's and the command's
+// buckets if all of them have one. Otherwise it returns how long to wait.
+func (rl *RateLimiter) Allow(user, channel, command string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	if len(rl.buckets) > rateLimitSweepSize {
+		rl.sweep(now)
+	}
+
+	var buckets []*bucket
+	add := func(key string, cfg *RateLimitCfg) {
+		if cfg == nil {
+			return
+		}
+		b, ok := rl.buckets[key]
+		if !ok {
+			b = &bucket{cfg: cfg, tokens: cfg.burst(), last: now}
+			rl.buckets[key] = b
+		}
+		b.refill(now)
+		buckets = append(buckets, b)
+	}
+	if user != "" {
+		add("user:"+user, rl.cfg.User)
+	}
+	if channel != "" {
+		add("channel:"+channel, rl.cfg.Channel)
+	}
+	if command != "" {
+		add("command:"+command, rl.cfg.Commands[command])
+	}
+
+	var wait time.Duration
+	for _, b := range buckets {
+		if w := b.wait(); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return false, wait
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0
+}
+
+// sweep forgets full buckets, they are the same as new ones.
+func (rl *RateLimiter) sweep(now time.Time) {
+	for key, b := range rl.buckets {
+		b.refill(now)
+		if b.tokens >= b.cfg.burst() {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// Message tells the user how long to wait, or that the limit is never
+// refilled.
+func (rl *RateLimiter) Message(wait time.Duration) string {
+	if wait == noRefill {
+		return noRefillMessage
+	}
+	msg := rl.cfg.Message
+	if msg == "" {
+		msg = defaultRateLimitMessage
+	}
+	if !strings.Contains(msg, "%s") {
+		return msg
+	}
+	return fmt.Sprintf(msg, roundWait(wait))
+}
+
+func roundWait(wait time.Duration) time.Duration {
+	if wait < time.Second {
+		return time.Second
+	}
+	return (wait + time.Second - 1).Truncate(time.Second)
+}
+
+// exceeded tells the user that m was over the limit, as configured.
+func (rl *RateLimiter) exceeded(m *Msg, wait time.Duration, replier *Replier) error {
+	if wait == noRefill {
+		log.Printf("Rate limit exceeded (user: %s, channel: %s, wait: no refill)", m.User, m.Channel)
+	} else {
+		log.Printf("Rate limit exceeded (user: %s, channel: %s, wait: %s)", m.User, m.Channel, wait)
+	}
+	switch rl.cfg.OnExceed {
+	case RateLimitReact:
+		if rl.api == nil || m.TS == "" {
+			return nil
+		}
+		emoji := rl.cfg.Emoji
+		if emoji == "" {
+			emoji = defaultRateLimitEmoji
+		}
+		return rl.api.AddReaction(m.Channel, m.TS, strings.Trim(emoji, ":"))
+	case RateLimitReply:
+		if replier == nil {
+			return nil
+		}
+		return replier.ReplyText(m, rl.Message(wait))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package wasb
+
+import (
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/dysfn/wasb/wasb/mrkdwn"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	defaultSplitMaxParts = 4
+	snippetFilename      = "reply.txt"
+)
+
+// Sender delivers outbound messages, either over an RTM websocket or
+// through the Web API.
+type Sender interface {
+	Send(m *Msg) error
+}
+
+type RTMSender struct {
+	conn   *websocket.Conn
+	nextID uint64
+}
+
+func NewRTMSender(conn *websocket.Conn) *RTMSender {
+	return &RTMSender{conn: conn}
+}
+
+func (s *RTMSender) Send(m *Msg) error {
+	out := *m
+	out.ID = atomic.AddUint64(&s.nextID, 1)
+	if out.Type == "" {
+		out.Type = "message"
+	}
+	return websocket.JSON.Send(s.conn, &out)
+}
+
+func (api *API) Send(m *Msg) error {
+	_, err := api.PostMessage(m)
+	return err
+}
+
+// ThreadPolicy decides whether replies to top level messages go into a
+// thread. Channels overrides Always for individual channel IDs.
+type ThreadPolicy struct {
+	Always   bool
+	Channels map[string]bool
+}
+
+func (p *ThreadPolicy) InThread(channel string) bool {
+	if p == nil {
+		return false
+	}
+	if inThread, ok := p.Channels[channel]; ok {
+		return inThread
+	}
+	return p.Always
+}
+
+// Uploader shares long text as a file when it would take too many
+// messages.
+type Uploader interface {
+	UploadSnippet(channel, threadTS, filename, content string) error
+}
+
+type Replier struct {
+	sender        Sender
+	uploader      Uploader
+	threads       *ThreadPolicy
+	splitInThread bool
+	splitMaxParts int
+}
+
+func NewReplier(sender Sender, cfg *Cfg) *Replier {
+	r := &Replier{
+		sender: sender,
+		threads: &ThreadPolicy{
+			Always:   cfg.ThreadReplies,
+			Channels: cfg.ThreadChannels,
+		},
+		splitInThread: cfg.SplitInThread,
+		splitMaxParts: cfg.SplitMaxParts,
+	}
+	if r.splitMaxParts <= 0 {
+		r.splitMaxParts = defaultSplitMaxParts
+	}
+	if u, ok := sender.(Uploader); ok {
+		r.uploader = u
+	}
+	return r
+}
+
+// WithUploader sets where replies too long to be split are uploaded, for
+// senders that cannot upload files themselves.
+func (r *Replier) WithUploader(u Uploader) *Replier {
+	r.uploader = u
+	return r
+}
+
+func (r *Replier) Sender() Sender {
+	return r.sender
+}
+
+// Reply answers m in its channel. Messages that are part of a thread are
+// always answered in that thread, top level messages according to the
+// thread policy.
+func (r *Replier) Reply(m *Msg, reply *Msg) error {
+	if m.ThreadTS != "" || r.threads.InThread(m.Channel) || r.splitInThread && isLong(reply) {
+		return r.ReplyInThread(m, reply, false)
+	}
+	out := *reply
+	out.Channel = m.Channel
+	return r.Send(&out)
+}
+
+// ReplyInThread answers m in the thread it belongs to, or starts one. With
+// broadcast set the reply is also shown in the channel.
+func (r *Replier) ReplyInThread(m *Msg, reply *Msg, broadcast bool) error {
+	out := *reply
+	out.Channel = m.Channel
+	out.ThreadTS = m.ThreadRoot()
+	out.ReplyBroadcast = broadcast
+	return r.Send(&out)
+}
+
+func (r *Replier) ReplyText(m *Msg, text string) error {
+	return r.Reply(m, &Msg{Text: text})
+}
+
+// Send delivers m, split into several messages if its text is over Slack's
+// limit. If that takes more than the configured number of parts the text
+// is uploaded as a snippet instead, given an uploader.
+func (r *Replier) Send(m *Msg) error {
+	if !isLong(m) {
+		return r.sender.Send(m)
+	}
+
+	parts := SplitText(m.Text, MaxMessageLength)
+	if len(parts) > r.splitMaxParts && r.uploader != nil {
+		return r.uploader.UploadSnippet(m.Channel, m.ThreadTS, snippetFilename, mrkdwn.Unescape(m.Text))
+	}
+	for i, part := range parts {
+		out := *m
+		out.Text = part
+		// Only broadcast the start of the reply
+		out.ReplyBroadcast = m.ReplyBroadcast && i == 0
+		err := r.sender.Send(&out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isLong(m *Msg) bool {
+	return len(m.Blocks) == 0 && utf8.RuneCountInString(m.Text) > MaxMessageLength
+}
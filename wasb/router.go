@@ -0,0 +1,409 @@
+package wasb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const responseURLTimeout = 10 * time.Second
+
+type HandlerFunc func(m *Msg) error
+
+type MatchFunc func(m *Msg) bool
+
+type messageRoute struct {
+	name           string
+	command        bool
+	match          MatchFunc
+	handler        HandlerFunc
+	commandHandler CommandFunc
+	filter         *Filter
+	priority       int
+	timeout        time.Duration
+	progress       *progress
+	commandInfo
+}
+
+type RouteOption func(mr *messageRoute)
+
+// Named gives a message handler a name, which access control applies to
+// like to a command name.
+func Named(name string) RouteOption {
+	return func(mr *messageRoute) { mr.name = name }
+}
+
+// Priority orders a message handler among the others: messages go to the
+// matching handler of highest priority, 0 by default. Handlers of equal
+// priority are tried in the order they were registered, so catch-all
+// handlers should use a negative priority to not depend on it.
+func Priority(p int) RouteOption {
+	return func(mr *messageRoute) { mr.priority = p }
+}
+
+// Router dispatches RTM/Events API messages, slash commands and interactive
+// payloads to registered handlers. It implements IsValidMessage and
+// SendMessage so a bot can embed it and only provide ReceiveMessage and
+// TearDown to satisfy WASB.
+type Router struct {
+	mu            sync.RWMutex
+	messages      []*messageRoute
+	slash         map[string]SlashHandlerFunc
+	slashRoutes   map[string]*messageRoute
+	actions       map[string]InteractionHandlerFunc
+	views         map[string]InteractionHandlerFunc
+	signingSecret string
+	client        *http.Client
+	self          *RespRTMStartSelf
+	acl           *ACL
+	limiter       *RateLimiter
+	replier       *Replier
+	conversations conversations
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+func NewRouter(signingSecret string) *Router {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Router{
+		slash:         make(map[string]SlashHandlerFunc),
+		slashRoutes:   make(map[string]*messageRoute),
+		actions:       make(map[string]InteractionHandlerFunc),
+		views:         make(map[string]InteractionHandlerFunc),
+		signingSecret: signingSecret,
+		client:        &http.Client{Timeout: responseURLTimeout},
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+}
+
+// SetSelf tells the router who the bot is so that its own messages can be
+// filtered out.
+func (r *Router) SetSelf(self *RespRTMStartSelf) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.self = self
+}
+
+// SetACL enforces access control before dispatching messages, slash
+// commands and interactions.
+func (r *Router) SetACL(acl *ACL) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.acl = acl
+}
+
+// SetRateLimiter limits how often messages and slash commands are
+// dispatched, after access control.
+func (r *Router) SetRateLimiter(limiter *RateLimiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limiter = limiter
+}
+
+// SetReplier sets how the router answers messages on its own behalf, e.g.
+// to deny access.
+func (r *Router) SetReplier(replier *Replier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replier = replier
+}
+
+func (r *Router) selfID() string {
+	if r.self == nil {
+		return ""
+	}
+	return r.self.ID
+}
+
+// HandleMessage registers a handler for messages matching match. Messages
+// dropped by the default filter only reach handlers that opt in to them.
+func (r *Router) HandleMessage(match MatchFunc, h HandlerFunc, opts ...RouteOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addRoute(&messageRoute{match: match, handler: h}, opts)
+}
+
+func (r *Router) addRoute(mr *messageRoute, opts []RouteOption) {
+	mr.filter = &Filter{}
+	for _, opt := range opts {
+		opt(mr)
+	}
+	// Keep routes sorted by priority, in registration order within one
+	i := len(r.messages)
+	for i > 0 && r.messages[i-1].priority < mr.priority {
+		i--
+	}
+	r.messages = append(r.messages, nil)
+	copy(r.messages[i+1:], r.messages[i:])
+	r.messages[i] = mr
+}
+
+// MessageFilter lets through anything at least one handler opted in to,
+// the routes filter further.
+func (r *Router) MessageFilter() *Filter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	filter := &Filter{SelfID: r.selfID()}
+	for _, mr := range r.messages {
+		filter.merge(mr.filter)
+	}
+	return filter
+}
+
+// HandleSlash registers a slash command handler. Options other than the
+// help metadata do not apply to slash commands.
+func (r *Router) HandleSlash(command string, h SlashHandlerFunc, opts ...RouteOption) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mr := &messageRoute{filter: &Filter{}}
+	for _, opt := range opts {
+		opt(mr)
+	}
+	r.slash[command] = h
+	r.slashRoutes[command] = mr
+}
+
+func (r *Router) HandleAction(actionID string, h InteractionHandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.actions[actionID] = h
+}
+
+func (r *Router) HandleView(callbackID string, h InteractionHandlerFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.views[callbackID] = h
+}
+
+// route finds the handler for m, and the command arguments if it is a
+// command.
+func (r *Router) route(m *Msg) (*messageRoute, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	selfID := r.selfID()
+	name, args, addressed := parseCommand(m, selfID)
+	accept := func(mr *messageRoute) bool {
+		filter := *mr.filter
+		filter.SelfID = selfID
+		return filter.Accept(m)
+	}
+
+	if addressed {
+		for _, mr := range r.messages {
+			if mr.command && mr.name == name && accept(mr) {
+				return mr, args
+			}
+		}
+		if name == helpCommand {
+			return r.helpRoute(), args
+		}
+	}
+	for _, mr := range r.messages {
+		if !mr.command && accept(mr) && mr.match(m) {
+			return mr, ""
+		}
+	}
+	return nil, ""
+}
+
+func (r *Router) IsValidMessage(m *Msg) bool {
+	if r.conversations.find(m) != nil {
+		return true
+	}
+	mr, _ := r.route(m)
+	return mr != nil
+}
+
+func (r *Router) SendMessage(m *Msg) error {
+	// Answers to pending questions go to whoever asked, as the command
+	// that asked. Questions outlive denied and limited answers.
+	if w := r.conversations.find(m); w != nil {
+		ok, err := r.allow(m, w.command, true)
+		if !ok {
+			return err
+		}
+		w = r.conversations.take(m)
+		if w == nil {
+			return nil
+		}
+		answer := *m
+		answer.command = w.command
+		return w.handler(&answer)
+	}
+
+	mr, args := r.route(m)
+	if mr == nil {
+		return nil
+	}
+	r.mu.RLock()
+	replier, selfID := r.replier, r.selfID()
+	r.mu.RUnlock()
+	ok, err := r.allow(m, mr.name, mr.command || addressed(m, selfID))
+	if !ok {
+		return err
+	}
+
+	return r.run(mr, m, args, replier)
+}
+
+// allow checks m against the ACL and rate limits as command, telling the
+// user when it is not allowed. Messages not addressed to the bot, only
+// matched by a passive route, are dropped silently when denied and are not
+// rate limited.
+func (r *Router) allow(m *Msg, command string, addressed bool) (bool, error) {
+	r.mu.RLock()
+	acl, limiter, replier := r.acl, r.limiter, r.replier
+	r.mu.RUnlock()
+	if acl != nil {
+		err := acl.check(m.Context(), m.User, m.Channel, command, addressed)
+		if err != nil {
+			if replier == nil || !addressed {
+				return false, nil
+			}
+			return false, replier.ReplyText(m, acl.DenialMessage())
+		}
+	}
+	// Chatter a passive route happens to match does not count
+	if limiter != nil && addressed {
+		ok, wait := limiter.Allow(m.User, m.Channel, command)
+		if !ok {
+			return false, limiter.exceeded(m, wait, replier)
+		}
+	}
+	return true, nil
+}
+
+// denied checks access for slash commands and interactions, which are
+// answered with an ephemeral response when denied.
+func (r *Router) denied(ctx context.Context, user, channel, command string) *Response {
+	r.mu.RLock()
+	acl := r.acl
+	r.mu.RUnlock()
+	if acl == nil || acl.Check(ctx, user, channel, command) == nil {
+		return nil
+	}
+	return &Response{
+		ResponseType: ResponseEphemeral,
+		Text:         acl.DenialMessage(),
+	}
+}
+
+// limited applies rate limits to slash commands and interactions.
+// Reactions are not possible there, so anything but dropping is answered
+// ephemerally.
+func (r *Router) limited(user, channel, command string) (*Response, bool) {
+	r.mu.RLock()
+	limiter := r.limiter
+	r.mu.RUnlock()
+	if limiter == nil {
+		return nil, false
+	}
+	ok, wait := limiter.Allow(user, channel, command)
+	if ok {
+		return nil, false
+	}
+	if limiter.cfg.OnExceed == RateLimitDrop || limiter.cfg.OnExceed == "" {
+		return nil, true
+	}
+	return &Response{
+		ResponseType: ResponseEphemeral,
+		Text:         limiter.Message(wait),
+	}, true
+}
+
+// PostResponse sends a (follow-up) response to a response_url handed out
+// with slash commands and interactive payloads.
+func (r *Router) PostResponse(responseURL string, resp *Response) error {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := r.client.Post(responseURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	ioutil.ReadAll(httpResp.Body)
+
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("response_url returned %s", httpResp.Status)
+	}
+	return nil
+}
+
+// respond runs a handler and writes its response straight into the HTTP
+// reply if it finishes before Slack's ack deadline. Slower handlers are
+// acked with an empty body and their response goes to responseURL once
+// ready.
+func (r *Router) respond(w http.ResponseWriter, responseURL string, p *progress, run func() (*Response, error)) {
+	type result struct {
+		resp *Response
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := run()
+		results <- result{resp: resp, err: err}
+	}()
+
+	timer := time.NewTimer(eventsAckTimeout)
+	defer timer.Stop()
+	select {
+	case res := <-results:
+		resp := res.resp
+		if res.err != nil {
+			log.Printf("Handler failed: %s", res.err)
+			resp = errorResponse
+		}
+		writeResponse(w, resp)
+	case <-timer.C:
+		// Slack shows an ack's body, so slow commands can say they are on it
+		if p != nil {
+			writeResponse(w, &Response{ResponseType: ResponseEphemeral, Text: p.text})
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		go func() {
+			res := <-results
+			resp := res.resp
+			if res.err != nil {
+				log.Printf("Handler failed: %s", res.err)
+				resp = errorResponse
+			}
+			if resp == nil || responseURL == "" {
+				return
+			}
+			err := r.PostResponse(responseURL, resp)
+			if err != nil {
+				log.Printf("Error posting deferred response: %s", err)
+			}
+		}()
+	}
+}
+
+func writeResponse(w http.ResponseWriter, resp interface{}) {
+	if resp == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r, ok := resp.(*Response); ok && r == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error encoding response: %s", err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
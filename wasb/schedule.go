@@ -0,0 +1,379 @@
+package wasb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	mrand "math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	schedulerNamespace = "wasb.scheduler"
+
+	// Built-in job posting Data["text"] to Data["channel"]
+	JobPostMessage = "wasb.post"
+)
+
+// JobFunc runs a scheduled job, giving up when ctx is done. Messages can be
+// posted through sender.
+type JobFunc func(ctx context.Context, job *Job, sender Sender) error
+
+// Job is a registered JobFunc scheduled to run at a time, and repeatedly if
+// it has a cron spec. Jobs are persisted with their data so that pending
+// jobs survive restarts.
+type Job struct {
+	ID       string            `json:"id"`
+	Func     string            `json:"func"`
+	Spec     string            `json:"spec,omitempty"`
+	TimeZone string            `json:"tz,omitempty"`
+	Jitter   time.Duration     `json:"jitter,omitempty"`
+	Data     map[string]string `json:"data,omitempty"`
+	Next     time.Time         `json:"next"`
+
+	cron *Cron
+	loc  *time.Location
+}
+
+// loadLocation looks up the job's time zone, the local one if unset.
+func (job *Job) loadLocation() error {
+	if job.TimeZone == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(job.TimeZone)
+	if err != nil {
+		return err
+	}
+	job.loc = loc
+	return nil
+}
+
+func (job *Job) location() *time.Location {
+	if job.loc == nil {
+		return time.Local
+	}
+	return job.loc
+}
+
+// schedule sets the next run of a cron job after t.
+func (job *Job) schedule(t time.Time) {
+	job.Next = job.cron.Next(t.In(job.location()))
+	if !job.Next.IsZero() && job.Jitter > 0 {
+		job.Next = job.Next.Add(time.Duration(mrand.Int63n(int64(job.Jitter))))
+	}
+}
+
+type JobOption func(job *Job)
+
+// InTimeZone matches cron specs in tz, an IANA name like "Europe/Paris",
+// rather than in local time. Cron and At reject unknown zones.
+func InTimeZone(tz string) JobOption {
+	return func(job *Job) { job.TimeZone = tz }
+}
+
+// WithJitter delays each run by a random duration up to jitter, to spread
+// out jobs scheduled at the same time.
+func WithJitter(jitter time.Duration) JobOption {
+	return func(job *Job) { job.Jitter = jitter }
+}
+
+func WithData(data map[string]string) JobOption {
+	return func(job *Job) { job.Data = data }
+}
+
+// WithID sets the job's ID, replacing any job with the same ID. Useful to
+// keep a single instance of a recurring job across restarts.
+func WithID(id string) JobOption {
+	return func(job *Job) { job.ID = id }
+}
+
+type Scheduler struct {
+	store  Store
+	sender Sender
+	mu     sync.Mutex
+	funcs  map[string]JobFunc
+	jobs   map[string]*Job
+	wake   chan struct{}
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	// Canceled by Stop to end running jobs
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewScheduler returns a scheduler persisting jobs to store and running
+// them with sender.
+func NewScheduler(store Store, sender Sender) *Scheduler {
+	s := &Scheduler{
+		store:  store,
+		sender: sender,
+		funcs:  make(map[string]JobFunc),
+		jobs:   make(map[string]*Job),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.Register(JobPostMessage, postMessageJob)
+	return s
+}
+
+func postMessageJob(ctx context.Context, job *Job, sender Sender) error {
+	return sender.Send(&Msg{
+		Type:    "message",
+		Channel: job.Data["channel"],
+		Text:    job.Data["text"],
+	})
+}
+
+// Register makes fn available to jobs under name. Register all functions
+// before Start so that persisted jobs find theirs.
+func (s *Scheduler) Register(name string, fn JobFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.funcs[name] = fn
+}
+
+// Cron schedules fn to run whenever spec matches.
+func (s *Scheduler) Cron(fn, spec string, opts ...JobOption) (*Job, error) {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{Func: fn, Spec: spec, cron: cron}
+	for _, opt := range opts {
+		opt(job)
+	}
+	err = job.loadLocation()
+	if err != nil {
+		return nil, err
+	}
+	job.schedule(time.Now())
+	if job.Next.IsZero() {
+		return nil, fmt.Errorf("cron: %q never matches", spec)
+	}
+	return job, s.add(job)
+}
+
+// At schedules fn to run once at t.
+func (s *Scheduler) At(fn string, t time.Time, opts ...JobOption) (*Job, error) {
+	job := &Job{Func: fn, Next: t}
+	for _, opt := range opts {
+		opt(job)
+	}
+	err := job.loadLocation()
+	if err != nil {
+		return nil, err
+	}
+	if job.Jitter > 0 {
+		job.Next = job.Next.Add(time.Duration(mrand.Int63n(int64(job.Jitter))))
+	}
+	return job, s.add(job)
+}
+
+// After schedules fn to run once after delay.
+func (s *Scheduler) After(fn string, delay time.Duration, opts ...JobOption) (*Job, error) {
+	return s.At(fn, time.Now().Add(delay), opts...)
+}
+
+// PostAt schedules a message to be posted to channel at t.
+func (s *Scheduler) PostAt(channel, text string, t time.Time) (*Job, error) {
+	return s.At(JobPostMessage, t, WithData(map[string]string{"channel": channel, "text": text}))
+}
+
+func (s *Scheduler) add(job *Job) error {
+	if job.ID == "" {
+		id := make([]byte, 8)
+		_, err := rand.Read(id)
+		if err != nil {
+			return err
+		}
+		job.ID = hex.EncodeToString(id)
+	}
+
+	s.mu.Lock()
+	s.jobs[job.ID] = job
+	err := s.persist(job)
+	s.mu.Unlock()
+
+	s.notify()
+	return err
+}
+
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	err := s.store.Delete(schedulerNamespace, id)
+	s.notify()
+	return err
+}
+
+func (s *Scheduler) Jobs() []*Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		j := *job
+		jobs = append(jobs, &j)
+	}
+	return jobs
+}
+
+func (s *Scheduler) persist(job *Job) error {
+	value, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(schedulerNamespace, job.ID, value, 0)
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// load restores persisted jobs. One-shot jobs that were due while the bot
+// was down run right away, cron jobs resume at their next match.
+func (s *Scheduler) load() error {
+	ids, err := s.store.List(schedulerNamespace, "")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, id := range ids {
+		value, err := s.store.Get(schedulerNamespace, id)
+		if err != nil {
+			continue
+		}
+		var job Job
+		err = json.Unmarshal(value, &job)
+		if err != nil {
+			log.Printf("Dropping unreadable job %s: %s", id, err)
+			s.store.Delete(schedulerNamespace, id)
+			continue
+		}
+		err = job.loadLocation()
+		if err != nil {
+			log.Printf("Dropping job %s: %s", id, err)
+			s.store.Delete(schedulerNamespace, id)
+			continue
+		}
+		if job.Spec != "" {
+			job.cron, err = ParseCron(job.Spec)
+			if err != nil {
+				log.Printf("Dropping job %s: %s", id, err)
+				s.store.Delete(schedulerNamespace, id)
+				continue
+			}
+			if job.Next.Before(now) {
+				job.schedule(now)
+			}
+		}
+		s.jobs[job.ID] = &job
+	}
+	return nil
+}
+
+// Start restores persisted jobs and runs jobs as they become due until
+// Stop is called.
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	err := s.load()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.wg.Add(1)
+	go s.loop()
+	return nil
+}
+
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+	for {
+		wait := s.runDue(time.Now())
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.done:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// runDue starts all due jobs and returns how long until the next one.
+func (s *Scheduler) runDue(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := time.Hour
+	for id, job := range s.jobs {
+		if job.Next.After(now) {
+			if d := job.Next.Sub(now); d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		fn, ok := s.funcs[job.Func]
+		if !ok {
+			log.Printf("No function %q registered for job %s", job.Func, id)
+			continue
+		}
+		run := *job
+		sender := s.sender
+		if api, ok := sender.(*API); ok {
+			sender = api.WithContext(s.ctx)
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			err := fn(s.ctx, &run, sender)
+			if err != nil {
+				log.Printf("Job %s (%s) failed: %s", run.ID, run.Func, err)
+			}
+		}()
+
+		if job.cron == nil {
+			delete(s.jobs, id)
+			s.store.Delete(schedulerNamespace, id)
+			continue
+		}
+		job.schedule(now)
+		if job.Next.IsZero() {
+			delete(s.jobs, id)
+			s.store.Delete(schedulerNamespace, id)
+			continue
+		}
+		err := s.persist(job)
+		if err != nil {
+			log.Printf("Error persisting job %s: %s", id, err)
+		}
+		if d := job.Next.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// Stop stops scheduling new runs, cancels the context of running jobs and
+// waits for them to return. Pending jobs stay persisted for the next
+// start.
+func (s *Scheduler) Stop() {
+	close(s.done)
+	s.cancel()
+	s.wg.Wait()
+}
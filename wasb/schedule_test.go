@@ -0,0 +1,30 @@
+package wasb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerTimeZone(t *testing.T) {
+	tests := []struct {
+		tz string
+		ok bool
+	}{
+		{"", true},
+		{"UTC", true},
+		{"Pacific/Auckland", true},
+		{"Pacific/Atlantis", false},
+	}
+
+	s := NewScheduler(NewMemoryStore(), nil)
+	for _, test := range tests {
+		_, err := s.Cron(JobPostMessage, "0 9 * * *", InTimeZone(test.tz))
+		if (err == nil) != test.ok {
+			t.Errorf("%q: Cron got error %v", test.tz, err)
+		}
+		_, err = s.After(JobPostMessage, time.Hour, InTimeZone(test.tz))
+		if (err == nil) != test.ok {
+			t.Errorf("%q: After got error %v", test.tz, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package wasb
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	ResponseInChannel = "in_channel"
+	ResponseEphemeral = "ephemeral"
+)
+
+var errorResponse = &Response{
+	ResponseType: ResponseEphemeral,
+	Text:         "Sorry, something went wrong while handling your request.",
+}
+
+type SlashCommand struct {
+	Token       string
+	TeamID      string
+	TeamDomain  string
+	ChannelID   string
+	ChannelName string
+	UserID      string
+	UserName    string
+	Command     string
+	Text        string
+	ResponseURL string
+	TriggerID   string
+	APIAppID    string
+
+	ctx context.Context
+}
+
+// Context returns the context c is handled in. It is canceled when the
+// router stops or the handler's deadline passes.
+func (c *SlashCommand) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+type Response struct {
+	ResponseType    string `json:"response_type,omitempty"`
+	Text            string `json:"text,omitempty"`
+	Blocks          Blocks `json:"blocks,omitempty"`
+	ReplaceOriginal bool   `json:"replace_original,omitempty"`
+	DeleteOriginal  bool   `json:"delete_original,omitempty"`
+}
+
+// SlashHandlerFunc handles a slash command. Responses returned within
+// Slack's 3 second deadline are sent immediately, later ones are deferred
+// to the command's response_url. A nil response only acks the command.
+type SlashHandlerFunc func(c *SlashCommand) (*Response, error)
+
+func parseSlashCommand(form url.Values) *SlashCommand {
+	return &SlashCommand{
+		Token:       form.Get("token"),
+		TeamID:      form.Get("team_id"),
+		TeamDomain:  form.Get("team_domain"),
+		ChannelID:   form.Get("channel_id"),
+		ChannelName: form.Get("channel_name"),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		ResponseURL: form.Get("response_url"),
+		TriggerID:   form.Get("trigger_id"),
+		APIAppID:    form.Get("api_app_id"),
+	}
+}
+
+// SlashHandler returns the http.Handler to use as the Request URL of the
+// app's slash commands.
+func (r *Router) SlashHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		_, err := VerifyRequest(req, r.signingSecret, time.Now())
+		if err != nil {
+			log.Printf("Rejecting slash command: %s", err)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		err = req.ParseForm()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		c := parseSlashCommand(req.PostForm)
+		r.mu.RLock()
+		h, ok := r.slash[c.Command]
+		mr := r.slashRoutes[c.Command]
+		r.mu.RUnlock()
+		if !ok {
+			writeResponse(w, &Response{
+				ResponseType: ResponseEphemeral,
+				Text:         "Unknown command " + c.Command,
+			})
+			return
+		}
+
+		if resp := r.denied(req.Context(), c.UserID, c.ChannelID, c.Command); resp != nil {
+			writeResponse(w, resp)
+			return
+		}
+		if resp, limited := r.limited(c.UserID, c.ChannelID, c.Command); limited {
+			writeResponse(w, resp)
+			return
+		}
+
+		r.respond(w, c.ResponseURL, mr.progress, func() (*Response, error) {
+			ctx, cancel := mr.context(r.ctx)
+			defer cancel()
+			c.ctx = ctx
+			return h(c)
+		})
+	})
+}
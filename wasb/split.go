@@ -0,0 +1,118 @@
+package wasb
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dysfn/wasb/wasb/mrkdwn"
+)
+
+const (
+	// Slack truncates or rejects messages longer than this
+	MaxMessageLength = 4000
+
+	codeFence = "```"
+)
+
+// Boundaries to split long text at, from most to least preferred
+var splitBoundaries = []string{"\n\n", "\n", ". ", "! ", "? ", " "}
+
+type span struct {
+	start, end int
+	codeBlock  bool
+}
+
+// SplitText breaks text into parts of at most limit characters. It prefers
+// paragraph, then line, sentence and word boundaries, never cuts through
+// markup like links or mentions, and closes and reopens code blocks that
+// have to be split.
+func SplitText(text string, limit int) []string {
+	var parts []string
+	for utf8.RuneCountInString(text) > limit {
+		part, rest := cutText(text, limit)
+		part = strings.TrimRight(part, " \n")
+		if part != "" {
+			parts = append(parts, part)
+		}
+		text = strings.TrimLeft(rest, " \n")
+	}
+	if text != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// runeOffset returns the byte offset of the n-th character of text, or its
+// length if it is shorter.
+func runeOffset(text string, n int) int {
+	for i := range text {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(text)
+}
+
+func cutText(text string, limit int) (string, string) {
+	window := runeOffset(text, limit)
+
+	spans := markupSpans(text)
+	inside := func(pos int) *span {
+		for i := range spans {
+			if spans[i].start < pos && pos < spans[i].end {
+				return &spans[i]
+			}
+		}
+		return nil
+	}
+
+	for _, boundary := range splitBoundaries {
+		pos := strings.LastIndex(text[:window], boundary)
+		for pos > 0 {
+			cut := pos + len(boundary)
+			if s := inside(cut); s == nil {
+				// Skip cuts that would leave a tiny first part if a
+				// later, finer boundary does better
+				if cut >= window/4 || boundary == " " {
+					return text[:cut], text[cut:]
+				}
+				break
+			}
+			pos = strings.LastIndex(text[:pos], boundary)
+		}
+	}
+
+	// No boundary outside of markup fits. A code block that does not fit
+	// has to be split inside and its fences repeated.
+	if s := inside(window); s != nil && s.codeBlock {
+		if s.start > 0 {
+			return text[:s.start], text[s.start:]
+		}
+		// Room for the closing fence, the cut on a character boundary
+		inner := runeOffset(text, limit-utf8.RuneCountInString(codeFence))
+		if nl := strings.LastIndex(text[:inner], "\n"); nl > len(codeFence) {
+			inner = nl + 1
+		}
+		return text[:inner] + codeFence, codeFence + text[inner:]
+	}
+	if s := inside(window); s != nil && s.start > 0 {
+		return text[:s.start], text[s.start:]
+	}
+
+	// Last resort, e.g. a single huge word
+	return text[:window], text[window:]
+}
+
+func markupSpans(text string) []span {
+	var spans []span
+	pos := 0
+	for _, t := range mrkdwn.Parse(text) {
+		end := pos + len(t.Raw)
+		if t.Kind != mrkdwn.TextToken && t.Kind != mrkdwn.EmojiToken {
+			spans = append(spans, span{start: pos, end: end, codeBlock: t.Kind == mrkdwn.CodeBlockToken})
+		}
+		pos = end
+	}
+	return spans
+}
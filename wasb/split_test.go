@@ -0,0 +1,51 @@
+package wasb
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		parts int
+	}{
+		{"short", "héllo wörld", 20, 1},
+		{"words", strings.Repeat("héllo wörld ", 500), MaxMessageLength, 2},
+		{"multi-byte word", strings.Repeat("é", 5000), MaxMessageLength, 2},
+		{"emoji", strings.Repeat("😀", 9000), MaxMessageLength, 3},
+		{"code block", codeFence + strings.Repeat("é", 5000) + codeFence, MaxMessageLength, 2},
+		{"code block lines", codeFence + strings.Repeat("ünïcödé\n", 1000) + codeFence, MaxMessageLength, 3},
+		{"text then code block", "Résumé:\n" + codeFence + strings.Repeat("日本語", 2000) + codeFence, MaxMessageLength, 3},
+		{"link", strings.Repeat("ça ", 1300) + "<https://example.com/é|lïen>", MaxMessageLength, 1},
+	}
+
+	// Text without what splitting adds or trims
+	strip := func(s string) string {
+		return strings.NewReplacer(codeFence, "", " ", "", "\n", "").Replace(s)
+	}
+
+	for _, test := range tests {
+		parts := SplitText(test.text, test.limit)
+		if len(parts) != test.parts {
+			t.Errorf("%s: got %d parts, want %d", test.name, len(parts), test.parts)
+		}
+		for i, part := range parts {
+			if !utf8.ValidString(part) {
+				t.Errorf("%s: part %d is not valid UTF-8", test.name, i)
+			}
+			if n := utf8.RuneCountInString(part); n > test.limit {
+				t.Errorf("%s: part %d has %d characters, over %d", test.name, i, n, test.limit)
+			}
+			if strings.Count(part, codeFence)%2 != 0 {
+				t.Errorf("%s: part %d leaves a code block open", test.name, i)
+			}
+		}
+		if strip(strings.Join(parts, "")) != strip(test.text) {
+			t.Errorf("%s: parts do not add up to the text", test.name)
+		}
+	}
+}
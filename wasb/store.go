@@ -0,0 +1,176 @@
+package wasb
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// Store is where bots keep state across messages and restarts. Keys live
+// in namespaces so that bots (or features of a bot) sharing a store do not
+// clash. A ttl of 0 keeps the value until it is deleted.
+type Store interface {
+	Get(namespace, key string) ([]byte, error)
+	Set(namespace, key string, value []byte, ttl time.Duration) error
+	Delete(namespace, key string) error
+	List(namespace, prefix string) ([]string, error)
+	Incr(namespace, key string, delta int64) (int64, error)
+	Close() error
+}
+
+// OpenStore returns a FileStore persisted to filename, or a MemoryStore if
+// filename is empty.
+func OpenStore(filename string) (Store, error) {
+	if filename == "" {
+		return NewMemoryStore(), nil
+	}
+	return NewFileStore(filename)
+}
+
+type storeEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func (e *storeEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+type MemoryStore struct {
+	mu   sync.Mutex
+	data map[string]map[string]*storeEntry
+	now  func() time.Time
+
+	// Entries in data, expired ones included
+	size int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: make(map[string]map[string]*storeEntry),
+		now:  time.Now,
+	}
+}
+
+func (s *MemoryStore) get(namespace, key string) *storeEntry {
+	e, ok := s.data[namespace][key]
+	if !ok {
+		return nil
+	}
+	if e.expired(s.now()) {
+		s.remove(namespace, key)
+		return nil
+	}
+	return e
+}
+
+func (s *MemoryStore) put(namespace, key string, e *storeEntry) {
+	ns, ok := s.data[namespace]
+	if !ok {
+		ns = make(map[string]*storeEntry)
+		s.data[namespace] = ns
+	}
+	if _, ok := ns[key]; !ok {
+		s.size++
+	}
+	ns[key] = e
+}
+
+func (s *MemoryStore) remove(namespace, key string) {
+	if _, ok := s.data[namespace][key]; ok {
+		s.size--
+	}
+	delete(s.data[namespace], key)
+	if len(s.data[namespace]) == 0 {
+		delete(s.data, namespace)
+	}
+}
+
+func (s *MemoryStore) Get(namespace, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.get(namespace, key)
+	if e == nil {
+		return nil, ErrNotFound
+	}
+	value := make([]byte, len(e.value))
+	copy(value, e.value)
+	return value, nil
+}
+
+func (s *MemoryStore) Set(namespace, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(namespace, key, s.entry(value, ttl))
+	return nil
+}
+
+func (s *MemoryStore) entry(value []byte, ttl time.Duration) *storeEntry {
+	e := &storeEntry{value: make([]byte, len(value))}
+	copy(e.value, value)
+	if ttl > 0 {
+		e.expires = s.now().Add(ttl)
+	}
+	return e
+}
+
+func (s *MemoryStore) Delete(namespace, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(namespace, key)
+	return nil
+}
+
+func (s *MemoryStore) List(namespace, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.data[namespace] {
+		if strings.HasPrefix(key, prefix) && s.get(namespace, key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *MemoryStore) Incr(namespace, key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.incremented(namespace, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	s.put(namespace, key, e)
+	return strconv.ParseInt(string(e.value), 10, 64)
+}
+
+// incremented returns a counter with delta added, keeping its expiry,
+// without storing it.
+func (s *MemoryStore) incremented(namespace, key string, delta int64) (*storeEntry, error) {
+	var n int64
+	e := s.get(namespace, key)
+	if e != nil {
+		var err error
+		n, err = strconv.ParseInt(string(e.value), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		e = &storeEntry{}
+	}
+
+	return &storeEntry{
+		value:   []byte(strconv.FormatInt(n+delta, 10)),
+		expires: e.expires,
+	}, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,271 @@
+package wasb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	storeOpSet    = "set"
+	storeOpDelete = "del"
+
+	// Compact once the log holds this many records and at least twice as
+	// many as there are live keys
+	fileStoreCompactMin = 1000
+)
+
+type storeRecord struct {
+	Op        string `json:"op"`
+	Namespace string `json:"ns"`
+	Key       string `json:"key"`
+	Value     []byte `json:"value,omitempty"`
+	Expires   int64  `json:"expires,omitempty"`
+}
+
+// FileStore keeps everything in memory and persists changes to an append
+// only log, which is replayed on open and compacted as it grows.
+type FileStore struct {
+	*MemoryStore
+	filename string
+	f        *os.File
+	records  int
+}
+
+func NewFileStore(filename string) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		filename:    filename,
+	}
+
+	end, size, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop a torn record, so that the next one starts on a line of its own
+	if size > end {
+		err = os.Truncate(filename, end)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.f, err = os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if size < end {
+		// The last record lacks its newline only
+		_, err = s.f.Write([]byte{'\n'})
+		if err != nil {
+			s.f.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// replay applies the records of the log. It returns the offset right after
+// the newline of the last good record and the size of the log, which is
+// smaller if that newline is missing.
+func (s *FileStore) replay() (int64, int64, error) {
+	f, err := os.Open(s.filename)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var end int64
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var r storeRecord
+		err = json.Unmarshal(scanner.Bytes(), &r)
+		if err != nil {
+			// A torn write at the end of the log is expected after a
+			// crash, anything else is corruption
+			if _, more := peek(scanner); !more {
+				break
+			}
+			return 0, 0, fmt.Errorf("%s:%d: %s", s.filename, line, err)
+		}
+		s.apply(&r)
+		s.records++
+		end += int64(len(scanner.Bytes())) + 1
+	}
+	return end, info.Size(), scanner.Err()
+}
+
+func peek(scanner *bufio.Scanner) ([]byte, bool) {
+	if scanner.Scan() {
+		return scanner.Bytes(), true
+	}
+	return nil, false
+}
+
+func (s *FileStore) apply(r *storeRecord) {
+	switch r.Op {
+	case storeOpSet:
+		e := &storeEntry{value: r.Value}
+		if r.Expires != 0 {
+			e.expires = time.Unix(0, r.Expires)
+		}
+		s.put(r.Namespace, r.Key, e)
+	case storeOpDelete:
+		s.remove(r.Namespace, r.Key)
+	}
+}
+
+func setRecord(namespace, key string, e *storeEntry) *storeRecord {
+	r := &storeRecord{Op: storeOpSet, Namespace: namespace, Key: key, Value: e.value}
+	if !e.expires.IsZero() {
+		r.Expires = e.expires.UnixNano()
+	}
+	return r
+}
+
+// append writes r to the log. Changes are applied in memory only once
+// written: a failed write changes nothing.
+func (s *FileStore) append(r *storeRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.f.Write(append(line, '\n'))
+	if err != nil {
+		return err
+	}
+	s.records++
+	return nil
+}
+
+// maybeCompact compacts the log once it has grown enough. Changes are
+// already written by then, so failures are only logged.
+func (s *FileStore) maybeCompact() {
+	if s.records < fileStoreCompactMin || s.records < 2*s.size {
+		return
+	}
+	err := s.compact()
+	if err != nil {
+		log.Printf("Error compacting store (file: %s): %s", s.filename, err)
+	}
+}
+
+func (s *FileStore) Set(namespace, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entry(value, ttl)
+	err := s.append(setRecord(namespace, key, e))
+	if err != nil {
+		return err
+	}
+	s.put(namespace, key, e)
+	s.maybeCompact()
+	return nil
+}
+
+func (s *FileStore) Delete(namespace, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.get(namespace, key) == nil {
+		return nil
+	}
+	err := s.append(&storeRecord{Op: storeOpDelete, Namespace: namespace, Key: key})
+	if err != nil {
+		return err
+	}
+	s.remove(namespace, key)
+	s.maybeCompact()
+	return nil
+}
+
+func (s *FileStore) Incr(namespace, key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.incremented(namespace, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	err = s.append(setRecord(namespace, key, e))
+	if err != nil {
+		return 0, err
+	}
+	s.put(namespace, key, e)
+	s.maybeCompact()
+	return strconv.ParseInt(string(e.value), 10, 64)
+}
+
+// Compact rewrites the log with only the live keys.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact()
+}
+
+// compact writes the live keys to a new log, which is renamed over the
+// current one and appended to from then on. The current log is kept on
+// failure.
+func (s *FileStore) compact() error {
+	tmp := s.filename + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	records := 0
+	now := s.now()
+	for namespace, ns := range s.data {
+		for key, e := range ns {
+			if e.expired(now) {
+				continue
+			}
+			line, err := json.Marshal(setRecord(namespace, key, e))
+			if err != nil {
+				f.Close()
+				return err
+			}
+			w.Write(line)
+			w.WriteByte('\n')
+			records++
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.filename)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	s.f.Close()
+	s.f = f
+	s.records = records
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.f.Sync()
+	if closeErr := s.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
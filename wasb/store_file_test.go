@@ -0,0 +1,68 @@
+package wasb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreTornTail(t *testing.T) {
+	good := `{"op":"set","ns":"ns","key":"a","value":"MQ=="}`
+	tests := []struct {
+		name string
+		log  string
+		keys []string
+	}{
+		{"empty", "", []string{"x", "y"}},
+		{"complete", good + "\n", []string{"a", "x", "y"}},
+		{"torn record", good + "\n" + `{"op":"set","ns":"ns","ke`, []string{"a", "x", "y"}},
+		{"torn only record", `{"op":"se`, []string{"x", "y"}},
+		{"missing newline", good, []string{"a", "x", "y"}},
+	}
+
+	dir, err := ioutil.TempDir("", "wasb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range tests {
+		filename := filepath.Join(dir, test.name)
+		err := ioutil.WriteFile(filename, []byte(test.log), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := NewFileStore(filename)
+		if err != nil {
+			t.Errorf("%s: opening: %s", test.name, err)
+			continue
+		}
+		for _, key := range []string{"x", "y"} {
+			err = s.Set("ns", key, []byte(key), 0)
+			if err != nil {
+				t.Errorf("%s: setting %s: %s", test.name, key, err)
+			}
+		}
+		s.Close()
+
+		s, err = NewFileStore(filename)
+		if err != nil {
+			t.Errorf("%s: reopening: %s", test.name, err)
+			continue
+		}
+		keys, _ := s.List("ns", "")
+		s.Close()
+		if len(keys) != len(test.keys) {
+			t.Errorf("%s: got keys %q, want %q", test.name, keys, test.keys)
+			continue
+		}
+		for i := range keys {
+			if keys[i] != test.keys[i] {
+				t.Errorf("%s: got keys %q, want %q", test.name, keys, test.keys)
+				break
+			}
+		}
+	}
+}
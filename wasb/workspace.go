@@ -0,0 +1,203 @@
+package wasb
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+
+	"golang.org/x/net/websocket"
+)
+
+// Workspace is a bot connected to one Slack workspace over RTM, with a
+// router that plugins register their handlers on. It implements WASB.
+type Workspace struct {
+	*Router
+	Name      string
+	Cfg       *Cfg
+	API       *API
+	Replier   *Replier
+	Self      *RespRTMStartSelf
+	Store     Store
+	Scheduler *Scheduler
+	plugins   []Plugin
+	conn      *websocket.Conn
+	events    *EventsReceiver
+}
+
+// Connect runs the startup sequence for cfg's workspace: start RTM, open
+// the websocket (or the Events API receiver) and set up the router with
+// access control and rate limits.
+func Connect(cfg *Cfg) (*Workspace, error) {
+	log.Printf("Starting RTM (workspace: %s)...", cfg.Name)
+	respRTMStart, err := StartRTM(cfg.APIToken)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("RTM started (workspace: %s)", cfg.Name)
+
+	var conn *websocket.Conn
+	var events *EventsReceiver
+	if cfg.Events {
+		log.Printf("Starting events receiver (workspace: %s)...", cfg.Name)
+		events, err = NewEventsReceiver(cfg.SigningSecret, cfg.Workers)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		log.Printf("Establishing Websocket connection (workspace: %s)...", cfg.Name)
+		conn, err = GetWSConn(respRTMStart.URL)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Websocket connection established (workspace: %s)", cfg.Name)
+	}
+
+	log.Printf("Opening store (workspace: %s, filename: %s)...", cfg.Name, cfg.StoreFile)
+	store, err := OpenStore(cfg.StoreFile)
+	if err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, err
+	}
+
+	api := NewAPI(cfg.APIToken)
+	replier := NewReplier(api, cfg)
+	router := NewRouter(cfg.SigningSecret)
+	router.SetSelf(respRTMStart.Self)
+	router.SetACL(NewACL(cfg.ACL, api))
+	router.SetRateLimiter(NewRateLimiter(cfg.RateLimits, api))
+	router.SetReplier(replier)
+
+	return &Workspace{
+		Router:    router,
+		Name:      cfg.Name,
+		Cfg:       cfg,
+		API:       api,
+		Replier:   replier,
+		Self:      respRTMStart.Self,
+		Store:     store,
+		Scheduler: NewScheduler(store, api),
+		conn:      conn,
+		events:    events,
+	}, nil
+}
+
+// EnablePlugin creates the plugin registered under name, initialises it
+// with cfg and registers its handlers.
+func (ws *Workspace) EnablePlugin(name string, cfg json.RawMessage) error {
+	p, err := NewPlugin(name)
+	if err != nil {
+		return err
+	}
+
+	svc := &Services{
+		Workspace: ws.Name,
+		Self:      ws.Self,
+		Log:       log.New(os.Stderr, "["+ws.Name+"/"+name+"] ", log.LstdFlags),
+		Store:     ws.Store,
+		API:       ws.API,
+		Replier:   ws.Replier,
+		Scheduler: ws.Scheduler,
+	}
+	err = p.Init(cfg, svc)
+	if err != nil {
+		return err
+	}
+	p.Register(ws.Router)
+
+	ws.plugins = append(ws.plugins, p)
+	log.Printf("Plugin enabled (workspace: %s, plugin: %s)", ws.Name, name)
+	return nil
+}
+
+// EnablePlugins enables the plugins listed in the workspace's config, in
+// name order.
+func (ws *Workspace) EnablePlugins() error {
+	names := make([]string, 0, len(ws.Cfg.Plugins))
+	for name := range ws.Cfg.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		err := ws.EnablePlugin(name, ws.Cfg.Plugins[name])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start runs the scheduler, then starts the enabled plugins. Call it once
+// all plugins are enabled.
+func (ws *Workspace) Start() error {
+	err := ws.Scheduler.Start()
+	if err != nil {
+		return err
+	}
+	for _, p := range ws.plugins {
+		err = p.Start()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ws *Workspace) ReceiveMessage() (*Msg, error) {
+	if ws.events != nil {
+		return ws.events.ReceiveMessage()
+	}
+	var m Msg
+	err := websocket.JSON.Receive(ws.conn, &m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// TearDown cancels pending slash commands, stops the plugins in reverse
+// order, then the scheduler, and closes the store and the websocket
+// connection or events receiver.
+func (ws *Workspace) TearDown() error {
+	ws.Router.Stop()
+
+	for i := len(ws.plugins) - 1; i >= 0; i-- {
+		p := ws.plugins[i]
+		log.Printf("Stopping plugin (workspace: %s, plugin: %s)...", ws.Name, p.Name())
+		err := p.Stop()
+		if err != nil {
+			log.Printf("Error stopping plugin %s: %s", p.Name(), err)
+		}
+	}
+	ws.Scheduler.Stop()
+
+	err := ws.Store.Close()
+	if err != nil {
+		log.Printf("Error closing store (workspace: %s): %s", ws.Name, err)
+	}
+
+	if ws.events != nil {
+		log.Printf("Closing events receiver (workspace: %s)...", ws.Name)
+		return ws.events.Close()
+	}
+	log.Printf("Closing websocket connection (workspace: %s)...", ws.Name)
+	err = ws.conn.Close()
+	return err
+}
+
+// Handler serves the workspace's slash commands and interactive payloads
+// under /commands and /interactive, and events under /events if it
+// receives them over HTTP.
+func (ws *Workspace) Handler() http.Handler {
+	mux := http.NewServeMux()
+	if ws.events != nil {
+		mux.Handle("/events", ws.events)
+	}
+	mux.Handle("/commands", ws.SlashHandler())
+	mux.Handle("/interactive", ws.InteractionHandler())
+	return mux
+}